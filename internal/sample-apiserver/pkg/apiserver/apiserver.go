@@ -121,5 +121,8 @@ func (c completedConfig) New() (*WardleServer, error) {
 		}
 	}
 
+	// change: apiserver-runtime
+	s.GenericAPIServer = ApplyGenericAPIServerFns(s.GenericAPIServer)
+
 	return s, nil
 }
@@ -30,6 +30,11 @@ var (
 	ServerOptionsFns      []func(server *ServerOptions) *ServerOptions
 	FlagsFns              []func(fs *pflag.FlagSet) *pflag.FlagSet
 	NewCommandStartServer = NewCommandStartWardleServer
+
+	// ServerOptionsErrFns and RecommendedConfigErrFns are applied after ServerOptionsFns and RecommendedConfigFns,
+	// and fail the start of the server if they return an error.
+	ServerOptionsErrFns     []func(server *ServerOptions) error
+	RecommendedConfigErrFns []func(*pkgserver.RecommendedConfig) error
 )
 
 type ServerOptions = WardleServerOptions
@@ -48,6 +53,24 @@ func ApplyRecommendedConfigFns(in *pkgserver.RecommendedConfig) *pkgserver.Recom
 	return in
 }
 
+func ApplyServerOptionsErrFns(in *ServerOptions) error {
+	for i := range ServerOptionsErrFns {
+		if err := ServerOptionsErrFns[i](in); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ApplyRecommendedConfigErrFns(in *pkgserver.RecommendedConfig) error {
+	for i := range RecommendedConfigErrFns {
+		if err := RecommendedConfigErrFns[i](in); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func ApplyFlagsFns(fs *pflag.FlagSet) *pflag.FlagSet {
 	for i := range FlagsFns {
 		fs = FlagsFns[i](fs)
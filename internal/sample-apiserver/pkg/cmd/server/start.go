@@ -178,7 +178,7 @@ func (o *WardleServerOptions) Complete() error {
 	//}
 
 	ApplyServerOptionsFns(o)
-	return nil
+	return ApplyServerOptionsErrFns(o)
 }
 
 // Config returns config for the api server given WardleServerOptions
@@ -218,6 +218,9 @@ func (o *WardleServerOptions) Config() (*apiserver.Config, error) {
 
 	// change: apiserver-runtime
 	serverConfig = ApplyRecommendedConfigFns(serverConfig)
+	if err := ApplyRecommendedConfigErrFns(serverConfig); err != nil {
+		return nil, err
+	}
 
 	config := &apiserver.Config{
 		GenericConfig: serverConfig,
@@ -25,6 +25,8 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/sets"
+	apiserverv1 "k8s.io/apiserver/pkg/apis/apiserver/v1"
+	registryrest "k8s.io/apiserver/pkg/registry/rest"
 	"sigs.k8s.io/apiserver-runtime/internal/sample-apiserver/pkg/apiserver"
 	"sigs.k8s.io/apiserver-runtime/internal/sample-apiserver/pkg/cmd/server"
 )
@@ -42,11 +44,19 @@ type Server struct {
 	orderedGroupVersions []schema.GroupVersion
 	schemes              []*runtime.Scheme
 	schemeBuilder        runtime.SchemeBuilder
+	subResources         []subResourceRegistration
+	// storageVersions are the storage versions of the resources, by GroupResource
+	storageVersions map[schema.GroupResource]schema.GroupVersionResource
+	// storages are the storages created for the storage versions, by GroupResource
+	storages map[schema.GroupResource]registryrest.Storage
+	// encryptionConfig is the configuration of WithEncryptionConfiguration
+	encryptionConfig *apiserverv1.EncryptionConfiguration
 }
 
 // Build returns a Command used to run the apiserver
 func (a *Server) Build() (*Command, error) {
 	a.schemes = append(a.schemes, apiserver.Scheme)
+	a.registerSubResources()
 	a.schemeBuilder.Register(
 		func(scheme *runtime.Scheme) error {
 			groupVersions := make(map[string]sets.Set[string])
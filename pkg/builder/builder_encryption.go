@@ -0,0 +1,115 @@
+package builder
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	apiserverv1 "k8s.io/apiserver/pkg/apis/apiserver/v1"
+	"k8s.io/apiserver/pkg/registry/generic"
+	pkgserver "k8s.io/apiserver/pkg/server"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/apiserver/pkg/storage/storagebackend"
+	"k8s.io/apiserver/pkg/storage/storagebackend/factory"
+	"k8s.io/apiserver/pkg/storage/value"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/kms/pkg/service"
+
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/encryption"
+)
+
+// WithEncryptionConfiguration encrypts the resources at rest with the providers of config, as the
+// "--encryption-provider-config" flag of the kube-apiserver does.  Each resource listed in config -- e.g.
+// "exampleresources.example.com" -- is encrypted with the aescbc, aesgcm, secretbox or KMS v2 provider configured
+// for it, in etcd and in the databases of the kine storages -- e.g. SQLite -- including without etcd.  It replaces
+// the "--encryption-provider-config" flag.
+//
+// Keys are rotated as in the kube-apiserver: the first provider of a resource encrypts, all of them decrypt, and
+// the objects are re-encrypted with the new key when they are next written, or by WithStorageVersionMigration.
+//
+// config, secrets included, is written to a file readable only by the current user while it is loaded at the start
+// of the apiserver, and removed once loaded: opts may set the private directory of the file, e.g.
+// encryption.WithConfigurationDir.
+//
+//	builder.APIServer.
+//	  WithResource(&v1alpha1.ExampleResource{}).
+//	  WithEncryptionConfiguration(&apiserverv1.EncryptionConfiguration{
+//	        Resources: []apiserverv1.ResourceConfiguration{{
+//	              Resources: []string{"exampleresources.example.com"},
+//	              Providers: []apiserverv1.ProviderConfiguration{
+//	                    {AESGCM: &apiserverv1.AESConfiguration{Keys: []apiserverv1.Key{{Name: "key1", Secret: secret}}}},
+//	                    {Identity: &apiserverv1.IdentityConfiguration{}},
+//	              },
+//	        }},
+//	  }).
+//	  Build()
+func (a *Server) WithEncryptionConfiguration(
+	config *apiserverv1.EncryptionConfiguration, opts ...encryption.Option) *Server {
+	a.encryptionConfig = config
+	return a.WithConfigErrFns(func(c *pkgserver.RecommendedConfig) error {
+		// the KMS plugins are called until the server is drained
+		ctx := wait.ContextForChannel(c.DrainedNotify())
+		transformers, err := encryption.NewResourceTransformers(ctx, config, opts...)
+		if err != nil {
+			return fmt.Errorf("failed loading encryption configuration: %w", err)
+		}
+		c.ResourceTransformers = transformers
+		c.RESTOptionsGetter = &encryptingRESTOptionsGetter{getter: c.RESTOptionsGetter, transformers: transformers}
+		return nil
+	})
+}
+
+// encryptingRESTOptionsGetter sets the transformer of each resource in the RESTOptions of getter, which the kine
+// storages copy.  getter is nil without the etcd options, e.g. with WithoutEtcd: the RESTOptions then only carry
+// the transformers, and fail creating etcd storages.
+type encryptingRESTOptionsGetter struct {
+	getter       generic.RESTOptionsGetter
+	transformers value.ResourceTransformers
+}
+
+func (g *encryptingRESTOptionsGetter) GetRESTOptions(
+	resource schema.GroupResource, example runtime.Object) (generic.RESTOptions, error) {
+	options := generic.RESTOptions{
+		StorageConfig:  &storagebackend.ConfigForResource{GroupResource: resource},
+		ResourcePrefix: resource.String(),
+		Decorator: func(*storagebackend.ConfigForResource, string, func(runtime.Object) (string, error),
+			func() runtime.Object, func() runtime.Object, storage.AttrFunc, storage.IndexerFuncs, *cache.Indexers,
+		) (storage.Interface, factory.DestroyFunc, error) {
+			return nil, nil, fmt.Errorf("no etcd storage for %v without the etcd options", resource)
+		},
+	}
+	if g.getter != nil {
+		var err error
+		if options, err = g.getter.GetRESTOptions(resource, example); err != nil {
+			return options, err
+		}
+		// the storage config may be shared
+		storageConfig := *options.StorageConfig
+		options.StorageConfig = &storageConfig
+	}
+	options.StorageConfig.Transformer = g.transformers.TransformerForResource(resource)
+	return options, nil
+}
+
+// WithKMSPlugin serves kms as a KMS v2 plugin on endpoint, e.g. "unix:///tmp/kms.sock", from the start of the
+// apiserver to its shutdown, for the KMS providers of WithEncryptionConfiguration with the same endpoint.
+// encryption.NewLocalKMS returns a plugin standing in for a key management service in tests and development.
+func (a *Server) WithKMSPlugin(endpoint string, kms service.Service) *Server {
+	var stop func()
+	a.WithOptionsErrFns(func(o *ServerOptions) error {
+		var err error
+		stop, err = encryption.ServeKMS(endpoint, kms)
+		if err != nil {
+			return fmt.Errorf("failed serving KMS plugin on %s: %w", endpoint, err)
+		}
+		return nil
+	})
+	a.WithServerFns(func(server *GenericAPIServer) *GenericAPIServer {
+		if stop != nil {
+			server.RegisterDestroyFunc(stop)
+		}
+		return server
+	})
+	return a
+}
@@ -0,0 +1,99 @@
+package builder
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apiserverv1 "k8s.io/apiserver/pkg/apis/apiserver/v1"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+	pkgserver "k8s.io/apiserver/pkg/server"
+
+	"sigs.k8s.io/apiserver-runtime/internal/sample-apiserver/pkg/apiserver"
+	"sigs.k8s.io/apiserver-runtime/internal/sample-apiserver/pkg/cmd/server"
+	builderrest "sigs.k8s.io/apiserver-runtime/pkg/builder/rest"
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/storage/sqlite"
+)
+
+func TestWithEncryptionConfigurationWithoutEtcd(t *testing.T) {
+	resetFns(t)
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	APIServer.WithEncryptionConfiguration(&apiserverv1.EncryptionConfiguration{
+		Resources: []apiserverv1.ResourceConfiguration{{
+			Resources: []string{"teststatusresources.test.k8s.io"},
+			Providers: []apiserverv1.ProviderConfiguration{
+				{AESCBC: &apiserverv1.AESConfiguration{Keys: []apiserverv1.Key{{Name: "key1", Secret: key}}}},
+			},
+		}},
+	})
+
+	// without etcd options, the config has no RESTOptionsGetter
+	config := pkgserver.NewRecommendedConfig(apiserver.Codecs)
+	config = server.ApplyRecommendedConfigFns(config)
+	if !assert.NoError(t, server.ApplyRecommendedConfigErrFns(config)) {
+		return
+	}
+
+	scheme := runtime.NewScheme()
+	groupVersion := schema.GroupVersion{Group: "test.k8s.io", Version: "v1"}
+	scheme.AddKnownTypes(groupVersion, &testStatusResource{})
+	metav1.AddToGroupVersion(scheme, groupVersion)
+	dir := t.TempDir()
+	storage, err := builderrest.NewWithFn(&testStatusResource{}, sqlite.NewSQLiteStorageProvider(
+		filepath.Join(dir, "state.db")))(scheme, config.RESTOptionsGetter)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer storage.Destroy()
+	ctx := genericapirequest.WithNamespace(context.TODO(), "default")
+	_, err = storage.(rest.Creater).Create(ctx, &testStatusResource{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+		Spec:       "plaintext-spec",
+	}, nil, &metav1.CreateOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	obj, err := storage.(rest.Getter).Get(ctx, "foo", &metav1.GetOptions{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "plaintext-spec", obj.(*testStatusResource).Spec)
+	}
+
+	// the database files hold the object encrypted only
+	var encrypted bool
+	files, _ := filepath.Glob(filepath.Join(dir, "state.db*"))
+	for _, file := range files {
+		if info, err := os.Stat(file); err != nil || !info.Mode().IsRegular() {
+			// the socket of the kine endpoint
+			continue
+		}
+		content, err := os.ReadFile(file)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.False(t, bytes.Contains(content, []byte("plaintext-spec")), "expected %s encrypted", file)
+		encrypted = encrypted || bytes.Contains(content, []byte("k8s:enc:aescbc:v1:key1:"))
+	}
+	assert.True(t, encrypted, "expected the object encrypted in %v", files)
+}
+
+func TestWithEncryptionConfigurationErrors(t *testing.T) {
+	resetFns(t)
+	APIServer.WithEncryptionConfiguration(&apiserverv1.EncryptionConfiguration{
+		Resources: []apiserverv1.ResourceConfiguration{{
+			Resources: []string{"teststatusresources.test.k8s.io"},
+			Providers: []apiserverv1.ProviderConfiguration{
+				{AESCBC: &apiserverv1.AESConfiguration{Keys: []apiserverv1.Key{{Name: "key1", Secret: "short"}}}},
+			},
+		}},
+	})
+	config := pkgserver.NewRecommendedConfig(apiserver.Codecs)
+	assert.Error(t, server.ApplyRecommendedConfigErrFns(config), "invalid configurations should fail the server")
+}
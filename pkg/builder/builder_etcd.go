@@ -0,0 +1,103 @@
+package builder
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+// embeddedEtcdStartTimeout is how long the embedded etcd server may take to start.
+const embeddedEtcdStartTimeout = time.Minute
+
+// WithEmbeddedEtcd stores the resources in an etcd server running in the apiserver process, with its data in
+// dataDir, rather than in an external etcd.  The server is started before the storage is created, listens on
+// loopback ports picked by the system, and is stopped when the apiserver shuts down.  A failure to start it fails the
+// start of the apiserver.  It replaces the "--etcd-servers" flag.
+//
+// The etcd history is compacted every hour by default, on top of the compaction done by the apiserver.  fns may
+// change the configuration of the server -- e.g. the compaction, its ports or its logs:
+//
+//	builder.APIServer.
+//	  WithResource(&v1alpha1.ExampleResource{}).
+//	  WithEmbeddedEtcd("data", func(c *embed.Config) {
+//	        c.AutoCompactionMode = embed.CompactorModeRevision
+//	        c.AutoCompactionRetention = "1000"
+//	  }).
+//	  Build()
+func (a *Server) WithEmbeddedEtcd(dataDir string, fns ...func(*embed.Config)) *Server {
+	var e *embed.Etcd
+	a.WithOptionsErrFns(func(o *ServerOptions) error {
+		if o.RecommendedOptions.Etcd == nil {
+			// removed by WithoutEtcd
+			return nil
+		}
+		cfg := newEmbeddedEtcdConfig(dataDir)
+		for _, fn := range fns {
+			fn(cfg)
+		}
+		var err error
+		e, err = startEmbeddedEtcd(cfg)
+		if err != nil {
+			return fmt.Errorf("failed starting embedded etcd: %w", err)
+		}
+		o.RecommendedOptions.Etcd.StorageConfig.Transport.ServerList = embeddedEtcdClientURLs(e)
+		return nil
+	})
+	a.WithServerFns(func(server *GenericAPIServer) *GenericAPIServer {
+		if e != nil {
+			// registered after the storage, so stopped once the storage has been destroyed
+			server.RegisterDestroyFunc(e.Close)
+		}
+		return server
+	})
+	return a
+}
+
+// newEmbeddedEtcdConfig returns the configuration of a single member etcd server storing its data in dataDir.  The
+// server listens on loopback ports picked by the system when it starts.
+func newEmbeddedEtcdConfig(dataDir string) *embed.Config {
+	loopbackURL := url.URL{Scheme: "http", Host: "127.0.0.1:0"}
+	cfg := embed.NewConfig()
+	cfg.Dir = dataDir
+	cfg.ListenClientUrls = []url.URL{loopbackURL}
+	cfg.AdvertiseClientUrls = []url.URL{loopbackURL}
+	cfg.ListenPeerUrls = []url.URL{loopbackURL}
+	cfg.AdvertisePeerUrls = []url.URL{loopbackURL}
+	// the JSON gateway dials the configured address, not the bound one, and the apiserver only needs gRPC
+	cfg.EnableGRPCGateway = false
+	cfg.AutoCompactionMode = embed.CompactorModePeriodic
+	cfg.AutoCompactionRetention = "1h"
+	cfg.LogLevel = "warn"
+	return cfg
+}
+
+// startEmbeddedEtcd starts an etcd server and waits until it is ready to serve requests.
+func startEmbeddedEtcd(cfg *embed.Config) (*embed.Etcd, error) {
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+	e, err := embed.StartEtcd(cfg)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-e.Server.ReadyNotify():
+		return e, nil
+	case err := <-e.Err():
+		e.Close()
+		return nil, err
+	case <-time.After(embeddedEtcdStartTimeout):
+		e.Close()
+		return nil, fmt.Errorf("etcd took longer than %v to start", embeddedEtcdStartTimeout)
+	}
+}
+
+// embeddedEtcdClientURLs returns the URLs of the addresses the etcd server listens on for clients, rather than the
+// advertised ones which keep the port 0 of the configuration.
+func embeddedEtcdClientURLs(e *embed.Etcd) []string {
+	var urls []string
+	for _, l := range e.Clients {
+		urls = append(urls, (&url.URL{Scheme: "http", Host: l.Addr().String()}).String())
+	}
+	return urls
+}
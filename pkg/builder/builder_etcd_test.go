@@ -0,0 +1,96 @@
+package builder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+	genericoptions "k8s.io/apiserver/pkg/server/options"
+	"k8s.io/apiserver/pkg/storage/storagebackend"
+
+	"sigs.k8s.io/apiserver-runtime/internal/sample-apiserver/pkg/cmd/server"
+)
+
+func TestEmbeddedEtcd(t *testing.T) {
+	dataDir := filepath.Join(t.TempDir(), "etcd")
+
+	put := func(key, value string) {
+		e, err := startEmbeddedEtcd(newEmbeddedEtcdConfig(dataDir))
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer e.Close()
+		client, err := clientv3.New(clientv3.Config{
+			Endpoints:   embeddedEtcdClientURLs(e),
+			DialTimeout: 10 * time.Second,
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer client.Close()
+
+		if value != "" {
+			_, err := client.Put(context.TODO(), key, value)
+			assert.NoError(t, err)
+			return
+		}
+		resp, err := client.Get(context.TODO(), key)
+		if assert.NoError(t, err) && assert.Len(t, resp.Kvs, 1) {
+			assert.Equal(t, "bar", string(resp.Kvs[0].Value))
+		}
+	}
+
+	put("foo", "bar")
+	// read back after a restart
+	put("foo", "")
+}
+
+func TestWithEmbeddedEtcd(t *testing.T) {
+	newOptions := func() *ServerOptions {
+		return &ServerOptions{RecommendedOptions: &genericoptions.RecommendedOptions{
+			Etcd: genericoptions.NewEtcdOptions(storagebackend.NewDefaultConfig("/registry", nil)),
+		}}
+	}
+
+	t.Run("the storage should use the embedded etcd", func(t *testing.T) {
+		resetFns(t)
+		APIServer.WithEmbeddedEtcd(filepath.Join(t.TempDir(), "etcd"))
+		options := newOptions()
+		if !assert.NoError(t, server.ApplyServerOptionsErrFns(options)) {
+			return
+		}
+		// stops etcd
+		newTestGenericAPIServer(t)
+		servers := options.RecommendedOptions.Etcd.StorageConfig.Transport.ServerList
+		if assert.Len(t, servers, 1) {
+			assert.NotEqual(t, "http://127.0.0.1:0", servers[0])
+		}
+	})
+
+	t.Run("failures to start etcd should be returned", func(t *testing.T) {
+		resetFns(t)
+		dataDir := filepath.Join(t.TempDir(), "etcd")
+		if !assert.NoError(t, os.WriteFile(dataDir, nil, 0o600)) {
+			return
+		}
+		APIServer.WithEmbeddedEtcd(dataDir)
+		assert.ErrorContains(t, server.ApplyServerOptionsErrFns(newOptions()), "failed starting embedded etcd")
+	})
+
+	t.Run("etcd should not be started without etcd options", func(t *testing.T) {
+		resetFns(t)
+		started := false
+		APIServer.WithEmbeddedEtcd(filepath.Join(t.TempDir(), "etcd"), func(*embed.Config) {
+			started = true
+		})
+		assert.NoError(t, server.ApplyServerOptionsErrFns(&ServerOptions{
+			RecommendedOptions: &genericoptions.RecommendedOptions{},
+		}))
+		assert.False(t, started)
+	})
+}
@@ -13,7 +13,8 @@ func (a *Server) WithOptionsFns(fns ...func(*ServerOptions) *ServerOptions) *Ser
 	return a
 }
 
-// WithServerFns sets functions to customize the GenericAPIServer
+// WithServerFns sets functions to customize the GenericAPIServer.  They are applied once the server is created and
+// its APIs are installed, before it is run.
 func (a *Server) WithServerFns(fns ...func(server *GenericAPIServer) *GenericAPIServer) *Server {
 	apiserver.GenericAPIServerFns = append(apiserver.GenericAPIServerFns, fns...)
 	return a
@@ -25,6 +26,20 @@ func (a *Server) WithConfigFns(fns ...func(config *pkgserver.RecommendedConfig)
 	return a
 }
 
+// WithOptionsErrFns sets functions to customize the ServerOptions used to create the apiserver, which fail its start
+// if they return an error.
+func (a *Server) WithOptionsErrFns(fns ...func(*ServerOptions) error) *Server {
+	server.ServerOptionsErrFns = append(server.ServerOptionsErrFns, fns...)
+	return a
+}
+
+// WithConfigErrFns sets functions to customize the RecommendedConfig, which fail the start of the apiserver if they
+// return an error.
+func (a *Server) WithConfigErrFns(fns ...func(config *pkgserver.RecommendedConfig) error) *Server {
+	server.RecommendedConfigErrFns = append(server.RecommendedConfigErrFns, fns...)
+	return a
+}
+
 // WithFlagFns sets functions to customize the flags for the compiled binary.
 func (a *Server) WithFlagFns(fns ...func(set *pflag.FlagSet) *pflag.FlagSet) *Server {
 	server.FlagsFns = append(server.FlagsFns, fns...)
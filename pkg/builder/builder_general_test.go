@@ -0,0 +1,54 @@
+package builder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	pkgserver "k8s.io/apiserver/pkg/server"
+	utilversion "k8s.io/apiserver/pkg/util/version"
+	restclient "k8s.io/client-go/rest"
+
+	"sigs.k8s.io/apiserver-runtime/internal/sample-apiserver/pkg/apiserver"
+	"sigs.k8s.io/apiserver-runtime/internal/sample-apiserver/pkg/cmd/server"
+)
+
+// resetFns restores the functions registered by the builder once the test is done, as they are global.
+func resetFns(t *testing.T) {
+	serverFns := apiserver.GenericAPIServerFns
+	optionsFns := server.ServerOptionsFns
+	configFns := server.RecommendedConfigFns
+	optionsErrFns := server.ServerOptionsErrFns
+	configErrFns := server.RecommendedConfigErrFns
+	t.Cleanup(func() {
+		apiserver.GenericAPIServerFns = serverFns
+		server.ServerOptionsFns = optionsFns
+		server.RecommendedConfigFns = configFns
+		server.ServerOptionsErrFns = optionsErrFns
+		server.RecommendedConfigErrFns = configErrFns
+	})
+}
+
+// newTestGenericAPIServer creates the GenericAPIServer of the sample apiserver, from a config without serving.
+func newTestGenericAPIServer(t *testing.T) *GenericAPIServer {
+	config := pkgserver.NewRecommendedConfig(apiserver.Codecs)
+	config.ExternalAddress = "127.0.0.1:443"
+	config.LoopbackClientConfig = &restclient.Config{}
+	config.EffectiveVersion = utilversion.NewEffectiveVersion("1.31")
+	s, err := (&apiserver.Config{GenericConfig: config}).Complete().New()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(s.GenericAPIServer.Destroy)
+	return s.GenericAPIServer
+}
+
+func TestWithServerFns(t *testing.T) {
+	resetFns(t)
+	var applied *GenericAPIServer
+	APIServer.WithServerFns(func(server *GenericAPIServer) *GenericAPIServer {
+		applied = server
+		return server
+	})
+	s := newTestGenericAPIServer(t)
+	assert.Same(t, s, applied, "the functions should be applied to the server created")
+}
@@ -0,0 +1,151 @@
+package builder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	apiserverv1 "k8s.io/apiserver/pkg/apis/apiserver/v1"
+	"k8s.io/apiserver/pkg/features"
+	registryrest "k8s.io/apiserver/pkg/registry/rest"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+
+	builderrest "sigs.k8s.io/apiserver-runtime/pkg/builder/rest"
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/migration"
+)
+
+// storageVersionReportingInterval is the interval between the attempts to report the storage versions.
+const storageVersionReportingInterval = 10 * time.Second
+
+// WithStorageVersionMigration rewrites the objects of every resource in its storage version -- the version whose
+// IsStorageVersion returns true -- once the apiserver has started, so that the objects written in a previous
+// storage version, or encrypted with a previous key of WithEncryptionConfiguration, can still be read once the old
+// version or key is removed.  Resources whose storage doesn't list and update their objects, e.g. resources served
+// by read-only handlers, are not migrated, nor are the resources stored by another server -- see
+// rest.ExternalStorage -- e.g. the resources served by the passthrough storage.
+//
+// The migration runs in the background, and logs its progress.  It is recorded in the file at statePath: a
+// migration interrupted by a shutdown resumes at the next start, and a completed migration runs again only when
+// the storage version of the resource or the encryption configuration changes.
+func (a *Server) WithStorageVersionMigration(statePath string) *Server {
+	return a.WithPostStartHook("storage-version-migration", func(hookContext genericapiserver.PostStartHookContext) error {
+		client, err := dynamic.NewForConfig(hookContext.LoopbackClientConfig)
+		if err != nil {
+			return err
+		}
+		trigger, err := encryptionConfigHash(a.encryptionConfig)
+		if err != nil {
+			return err
+		}
+		migrator := migration.NewMigrator(client, statePath, migration.WithTrigger(trigger))
+		gvrs := a.storageVersionsToMigrate()
+		go func() {
+			for _, gvr := range gvrs {
+				if err := migrator.Migrate(hookContext, gvr); err != nil {
+					klog.Errorf("Failed migrating %v to %s: %v", gvr.GroupResource(), gvr.Version, err)
+				}
+			}
+		}()
+		return nil
+	})
+}
+
+// storageVersionsToMigrate returns the storage versions of the resources whose storage lists and updates their
+// objects, and stores them, sorted by GroupResource.
+func (a *Server) storageVersionsToMigrate() []schema.GroupVersionResource {
+	var gvrs []schema.GroupVersionResource
+	for gr, gvr := range a.storageVersions {
+		storage := a.storages[gr]
+		if external, ok := storage.(builderrest.ExternalStorage); ok && external.IsExternalStorage() {
+			klog.V(2).Infof("Not migrating %v, its objects are stored by another server", gr)
+			continue
+		}
+		if _, ok := storage.(registryrest.Updater); !ok {
+			klog.V(2).Infof("Not migrating %v, its storage doesn't update objects", gr)
+			continue
+		}
+		if _, ok := storage.(registryrest.Lister); !ok {
+			klog.V(2).Infof("Not migrating %v, its storage doesn't list objects", gr)
+			continue
+		}
+		gvrs = append(gvrs, gvr)
+	}
+	sort.Slice(gvrs, func(i, j int) bool {
+		return gvrs[i].GroupResource().String() < gvrs[j].GroupResource().String()
+	})
+	return gvrs
+}
+
+// encryptionConfigHash returns a hash of config without its secrets, which changes when the keys are rotated as
+// their names change, or "" without config.
+func encryptionConfigHash(config *apiserverv1.EncryptionConfiguration) (string, error) {
+	if config == nil {
+		return "", nil
+	}
+	config = config.DeepCopy()
+	for i := range config.Resources {
+		for _, provider := range config.Resources[i].Providers {
+			for _, aes := range []*apiserverv1.AESConfiguration{provider.AESGCM, provider.AESCBC} {
+				if aes != nil {
+					clearSecrets(aes.Keys)
+				}
+			}
+			if provider.Secretbox != nil {
+				clearSecrets(provider.Secretbox.Keys)
+			}
+		}
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+func clearSecrets(keys []apiserverv1.Key) {
+	for i := range keys {
+		keys[i].Secret = ""
+	}
+}
+
+// WithStorageVersionReporting reports the storage versions of the resources to the StorageVersion API of the
+// kube-apiserver -- internal.apiserver.k8s.io/v1alpha1 -- once the apiserver has started, for the storage version
+// migrators relying on it.  The StorageVersionAPI and APIServerIdentity feature gates must be enabled with the
+// "--feature-gates" flag; the versions are reported with the client of the "--kubeconfig" flag.
+func (a *Server) WithStorageVersionReporting() *Server {
+	return a.WithConfigErrFns(func(config *genericapiserver.RecommendedConfig) error {
+		featureGate := config.FeatureGate
+		if featureGate == nil {
+			// defaulted when the config is completed
+			featureGate = utilfeature.DefaultFeatureGate
+		}
+		if !featureGate.Enabled(features.StorageVersionAPI) || !featureGate.Enabled(features.APIServerIdentity) {
+			klog.Warningf("Not reporting storage versions, the %s and %s feature gates are disabled",
+				features.StorageVersionAPI, features.APIServerIdentity)
+			return nil
+		}
+		report := func(hookContext genericapiserver.PostStartHookContext) error {
+			if config.ClientConfig == nil {
+				klog.Warningf("Not reporting storage versions, no kube-apiserver is configured")
+				return nil
+			}
+			go func() {
+				_ = wait.PollUntilContextCancel(hookContext, storageVersionReportingInterval, true,
+					func(context.Context) (bool, error) {
+						config.StorageVersionManager.UpdateStorageVersions(config.ClientConfig, config.APIServerID)
+						return config.StorageVersionManager.Completed(), nil
+					})
+			}()
+			return nil
+		}
+		return config.AddPostStartHook("storage-version-reporting", report)
+	})
+}
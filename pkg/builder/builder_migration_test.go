@@ -0,0 +1,129 @@
+package builder
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apiserverv1 "k8s.io/apiserver/pkg/apis/apiserver/v1"
+	"k8s.io/apiserver/pkg/features"
+	"k8s.io/apiserver/pkg/registry/generic"
+	registryrest "k8s.io/apiserver/pkg/registry/rest"
+	pkgserver "k8s.io/apiserver/pkg/server"
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
+	featuregatetesting "k8s.io/component-base/featuregate/testing"
+
+	"sigs.k8s.io/apiserver-runtime/internal/sample-apiserver/pkg/apiserver"
+	"sigs.k8s.io/apiserver-runtime/internal/sample-apiserver/pkg/cmd/server"
+)
+
+func TestWithStorageVersionMigration(t *testing.T) {
+	resetFns(t)
+	a := &Server{storageProvider: map[schema.GroupResource]*singletonProvider{}}
+	a.WithStorageVersionMigration(filepath.Join(t.TempDir(), "migration.json"))
+	s := newTestGenericAPIServer(t)
+	assert.Contains(t, s.PostStartHooks(), "storage-version-migration")
+
+	t.Run("resources whose storage doesn't update objects should not be migrated", func(t *testing.T) {
+		readOnlyGVR := (&testStatusResource{}).GetGroupVersionResource()
+		standardGVR := (&testScaleResource{}).GetGroupVersionResource()
+		defer func() {
+			delete(apiserver.APIs, readOnlyGVR)
+			delete(apiserver.APIs, standardGVR)
+		}()
+		a.addToScheme(&testStatusResource{})
+		a.forGroupVersionResource(readOnlyGVR, func(*runtime.Scheme, generic.RESTOptionsGetter) (registryrest.Storage, error) {
+			return &testParentStorage{}, nil
+		})
+		a.addToScheme(&testScaleResource{})
+		a.forGroupVersionResource(standardGVR, func(*runtime.Scheme, generic.RESTOptionsGetter) (registryrest.Storage, error) {
+			return &testStandardParentStorage{}, nil
+		})
+		// the storages are created when the APIs are installed
+		for _, gvr := range []schema.GroupVersionResource{readOnlyGVR, standardGVR} {
+			_, err := apiserver.APIs[gvr](runtime.NewScheme(), nil)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, []schema.GroupVersionResource{standardGVR}, a.storageVersionsToMigrate())
+	})
+
+	t.Run("resources stored by another server should not be migrated", func(t *testing.T) {
+		gvr := (&testScaleResource{}).GetGroupVersionResource()
+		a := &Server{
+			storageVersions: map[schema.GroupResource]schema.GroupVersionResource{gvr.GroupResource(): gvr},
+			storages:        map[schema.GroupResource]registryrest.Storage{gvr.GroupResource(): &testExternalStorage{}},
+		}
+		assert.Empty(t, a.storageVersionsToMigrate())
+	})
+}
+
+type testExternalStorage struct {
+	testStandardParentStorage
+}
+
+func (s *testExternalStorage) IsExternalStorage() bool {
+	return true
+}
+
+func TestEncryptionConfigHash(t *testing.T) {
+	config := func(name, secret string) *apiserverv1.EncryptionConfiguration {
+		return &apiserverv1.EncryptionConfiguration{
+			Resources: []apiserverv1.ResourceConfiguration{{
+				Resources: []string{"teststatusresources.test.k8s.io"},
+				Providers: []apiserverv1.ProviderConfiguration{
+					{AESCBC: &apiserverv1.AESConfiguration{Keys: []apiserverv1.Key{{Name: name, Secret: secret}}}},
+				},
+			}},
+		}
+	}
+	hash, err := encryptionConfigHash(config("key1", "secret1"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, hash)
+
+	rotated, err := encryptionConfigHash(config("key2", "secret2"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, rotated, "rotating the keys should change the hash")
+
+	sameNames, err := encryptionConfigHash(config("key1", "secret2"))
+	assert.NoError(t, err)
+	assert.Equal(t, hash, sameNames, "the secrets should not be hashed")
+
+	none, err := encryptionConfigHash(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, none)
+}
+
+func TestWithStorageVersionReporting(t *testing.T) {
+	newConfig := func() *pkgserver.RecommendedConfig {
+		config := pkgserver.NewRecommendedConfig(apiserver.Codecs)
+		config = server.ApplyRecommendedConfigFns(config)
+		return config
+	}
+
+	t.Run("storage versions should not be reported with the feature gates disabled", func(t *testing.T) {
+		resetFns(t)
+		featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.StorageVersionAPI, false)
+		APIServer.WithStorageVersionReporting()
+		config := newConfig()
+		assert.NoError(t, server.ApplyRecommendedConfigErrFns(config))
+		assert.NotContains(t, config.PostStartHooks, "storage-version-reporting")
+	})
+	t.Run("storage versions should be reported with the feature gates enabled", func(t *testing.T) {
+		resetFns(t)
+		featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.StorageVersionAPI, true)
+		featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.APIServerIdentity, true)
+		APIServer.WithStorageVersionReporting()
+		config := newConfig()
+		assert.NoError(t, server.ApplyRecommendedConfigErrFns(config))
+		assert.Contains(t, config.PostStartHooks, "storage-version-reporting")
+	})
+	t.Run("failures to register the post-start hook should be returned", func(t *testing.T) {
+		resetFns(t)
+		featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.StorageVersionAPI, true)
+		featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.APIServerIdentity, true)
+		APIServer.WithStorageVersionReporting().WithStorageVersionReporting()
+		assert.ErrorContains(t, server.ApplyRecommendedConfigErrFns(newConfig()), "storage-version-reporting")
+	})
+}
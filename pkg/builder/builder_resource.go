@@ -17,11 +17,15 @@ limitations under the License.
 package builder
 
 import (
+	"fmt"
+	"reflect"
 	"strings"
 
 	"k8s.io/klog/v2"
 
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/registry/generic"
 	regsitryrest "k8s.io/apiserver/pkg/registry/rest"
 	"sigs.k8s.io/apiserver-runtime/internal/sample-apiserver/pkg/apiserver"
 	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource"
@@ -56,7 +60,7 @@ import (
 // to the object version which the handler accepts before the handler is invoked.
 func (a *Server) WithResource(obj resource.Object) *Server {
 	gvr := obj.GetGroupVersionResource()
-	a.schemeBuilder.Register(resource.AddToScheme(obj))
+	a.addToScheme(obj)
 
 	// reuse the storage if this resource has already been registered
 	if s, found := a.storageProvider[gvr.GroupResource()]; found {
@@ -92,7 +96,7 @@ func (a *Server) WithResource(obj resource.Object) *Server {
 // another version.
 func (a *Server) WithResourceAndStrategy(obj resource.Object, strategy rest.Strategy) *Server {
 	gvr := obj.GetGroupVersionResource()
-	a.schemeBuilder.Register(resource.AddToScheme(obj))
+	a.addToScheme(obj)
 
 	parentStorageProvider := rest.NewWithStrategy(obj, strategy)
 	_ = a.forGroupVersionResource(gvr, parentStorageProvider)
@@ -115,7 +119,7 @@ func (a *Server) WithResourceAndStrategy(obj resource.Object, strategy rest.Stra
 // Note: WithResourceAndHandler will NOT register the "status" subresource for the resource object.
 func (a *Server) WithResourceAndHandler(obj resource.Object, sp rest.ResourceHandlerProvider) *Server {
 	gvr := obj.GetGroupVersionResource()
-	a.schemeBuilder.Register(resource.AddToScheme(obj))
+	a.addToScheme(obj)
 	defer func() {
 		// automatically create status subresource if the object implements the status interface
 		a.withSubResourceIfExists(obj, sp)
@@ -133,7 +137,7 @@ func (a *Server) WithResourceAndHandler(obj resource.Object, sp rest.ResourceHan
 // another version.
 func (a *Server) WithResourceAndStorage(obj resource.Object, fn rest.StoreFn) *Server {
 	gvr := obj.GetGroupVersionResource()
-	a.schemeBuilder.Register(resource.AddToScheme(obj))
+	a.addToScheme(obj)
 	sp := rest.NewWithFn(obj, fn)
 	defer func() {
 		// automatically create status subresource if the object implements the status interface
@@ -142,6 +146,96 @@ func (a *Server) WithResourceAndStorage(obj resource.Object, fn rest.StoreFn) *S
 	return a.forGroupVersionResource(gvr, sp)
 }
 
+// WithResourceAndStorageProvider registers a request handler for the resource rather than the default
+// etcd backed storage.  It is equivalent to WithResourceAndHandler and may be used with a rest.ResourceStorageFn
+// -- e.g. WithResourceAndStorageProvider(fn()).
+func (a *Server) WithResourceAndStorageProvider(obj resource.Object, sp rest.ResourceHandlerProvider) *Server {
+	return a.WithResourceAndHandler(obj, sp)
+}
+
+// WithSubResourceAndStorageProvider registers a subresource under the path of the parent resource -- e.g. "scale"
+// for ".../deployments/NAME/scale" -- using the provided request handler for the subresource.  It may be used
+// with a rest.SubResourceStorageFn -- e.g. WithSubResourceAndStorageProvider(fn()).
+//
+// The parent resource must be registered separately, either before or after the subresource.  The subresource
+// is registered for every version of the parent GroupResource.  The request object is added to the known types
+// for each version unless it is the parent type, and may be nil if the handler already registers its own types.
+//
+// The storage of the parent resource is plumbed in the context of requests to the subresource, and may be
+// retrieved using the "apiserver-runtime/pkg/util/context" package.
+func (a *Server) WithSubResourceAndStorageProvider(
+	path string, parent resource.Object, request resource.Object, sp rest.ResourceHandlerProvider) *Server {
+	a.subResources = append(a.subResources, subResourceRegistration{
+		path:     path,
+		parent:   parent,
+		request:  request,
+		provider: sp,
+	})
+	return a
+}
+
+// WithSubResourceAndStrategy registers a subresource under the path of the parent resource, creating a new etcd
+// backed storage for the subresource using the provided strategy.
+//
+// The parent resource must be registered separately, either before or after the subresource.  The subresource
+// is registered for every version of the parent GroupResource.
+func (a *Server) WithSubResourceAndStrategy(
+	parent resource.Object, subResource resource.SubResource, strategy rest.Strategy) *Server {
+	return a.WithSubResourceAndStorageProvider(subResource.SubResourceName(), parent, nil,
+		rest.NewSubResourceWithStrategy(parent, subResource, strategy))
+}
+
+type subResourceRegistration struct {
+	path     string
+	parent   resource.Object
+	request  resource.Object
+	provider rest.ResourceHandlerProvider
+}
+
+// registerSubResources registers the explicitly registered subresources for every version of their parents.
+func (a *Server) registerSubResources() {
+	for i := range a.subResources {
+		sub := a.subResources[i]
+		parentGR := sub.parent.GetGroupVersionResource().GroupResource()
+		parentProvider, found := a.storageProvider[parentGR]
+		if !found {
+			a.errs = append(a.errs, fmt.Errorf("parent resource %v of subresource %q must be registered", parentGR, sub.path))
+			continue
+		}
+		parentGVRs := []schema.GroupVersionResource{}
+		for gvr := range apiserver.APIs {
+			if gvr.GroupResource() == parentGR {
+				parentGVRs = append(parentGVRs, gvr)
+			}
+		}
+		// share the subresource storage across versions like the parent storage
+		subResourceProvider := &singletonProvider{Provider: sub.provider}
+		for _, gvr := range parentGVRs {
+			subResourceGVR := gvr.GroupVersion().WithResource(gvr.Resource + "/" + sub.path)
+			a.forGroupVersionSubResource(subResourceGVR, parentProvider.Get, subResourceProvider.Get)
+			if sub.request != nil && reflect.TypeOf(sub.request.New()) != reflect.TypeOf(sub.parent.New()) {
+				gv := gvr.GroupVersion()
+				a.schemeBuilder.Register(func(s *runtime.Scheme) error {
+					s.AddKnownTypes(gv, sub.request.New())
+					return nil
+				})
+			}
+		}
+	}
+}
+
+// addToScheme registers obj with the schemes built, and records the storage version of its GroupResource.
+func (a *Server) addToScheme(obj resource.Object) {
+	a.schemeBuilder.Register(resource.AddToScheme(obj))
+	if obj.IsStorageVersion() {
+		if a.storageVersions == nil {
+			a.storageVersions = map[schema.GroupResource]schema.GroupVersionResource{}
+		}
+		gvr := obj.GetGroupVersionResource()
+		a.storageVersions[gvr.GroupResource()] = gvr
+	}
+}
+
 // forGroupVersionResource manually registers storage for a specific resource.
 func (a *Server) forGroupVersionResource(
 	gvr schema.GroupVersionResource, sp rest.ResourceHandlerProvider) *Server {
@@ -155,10 +249,25 @@ func (a *Server) forGroupVersionResource(
 		a.storageProvider[gvr.GroupResource()] = &singletonProvider{Provider: sp}
 	}
 	// add the API with its storageProvider
-	apiserver.APIs[gvr] = sp
+	apiserver.APIs[gvr] = a.recordStorage(gvr, sp)
 	return a
 }
 
+// recordStorage returns sp, recording the storage it creates if gvr is the storage version of its resource.
+func (a *Server) recordStorage(gvr schema.GroupVersionResource, sp rest.ResourceHandlerProvider) rest.ResourceHandlerProvider {
+	return func(scheme *runtime.Scheme, optsGetter generic.RESTOptionsGetter) (regsitryrest.Storage, error) {
+		storage, err := sp(scheme, optsGetter)
+		if err != nil || a.storageVersions[gvr.GroupResource()] != gvr {
+			return storage, err
+		}
+		if a.storages == nil {
+			a.storages = map[schema.GroupResource]regsitryrest.Storage{}
+		}
+		a.storages[gvr.GroupResource()] = storage
+		return storage, nil
+	}
+}
+
 // forGroupVersionSubResource manually registers storageProvider for a specific subresource.
 func (a *Server) forGroupVersionSubResource(
 	gvr schema.GroupVersionResource, parentProvider rest.ResourceHandlerProvider, subResourceProvider rest.ResourceHandlerProvider) {
@@ -197,7 +306,15 @@ func (a *Server) withSubResourceIfExists(obj resource.Object, parentStorageProvi
 		statusGVR := parentGVR.GroupVersion().WithResource(parentGVR.Resource + "/status")
 		a.forGroupVersionSubResource(statusGVR, parentStorageProvider, nil)
 	}
-	if _, ok := obj.(resource.ObjectWithScaleSubResource); ok {
+	_, hasScale := obj.(resource.ObjectWithScaleSubResource)
+	scalePaths, hasScalePaths := obj.(resource.ObjectWithScaleSubResourcePaths)
+	if hasScalePaths {
+		if err := validateScaleSubResourcePaths(scalePaths.GetScaleSubResourcePaths()); err != nil {
+			a.errs = append(a.errs, fmt.Errorf("invalid scale subresource paths of %v: %w", parentGVR, err))
+			hasScalePaths = false
+		}
+	}
+	if hasScale || hasScalePaths {
 		subResourceGVR := parentGVR.GroupVersion().WithResource(parentGVR.Resource + "/scale")
 		a.forGroupVersionSubResource(subResourceGVR, parentStorageProvider, nil)
 	}
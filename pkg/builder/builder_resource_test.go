@@ -0,0 +1,112 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/registry/generic"
+	registryrest "k8s.io/apiserver/pkg/registry/rest"
+
+	"sigs.k8s.io/apiserver-runtime/internal/sample-apiserver/pkg/apiserver"
+	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource"
+)
+
+func TestWithSubResourceAndStorageProvider(t *testing.T) {
+	parent := &testStatusResource{}
+	parentGVR := parent.GetGroupVersionResource()
+	v2GVR := schema.GroupVersionResource{Group: parentGVR.Group, Version: "v2", Resource: parentGVR.Resource}
+	sub := &testEvictionSubResource{}
+	subProvider := func(*runtime.Scheme, generic.RESTOptionsGetter) (registryrest.Storage, error) {
+		return sub, nil
+	}
+	parentProvider := func(*runtime.Scheme, generic.RESTOptionsGetter) (registryrest.Storage, error) {
+		return &testParentStorage{}, nil
+	}
+	defer func() {
+		for _, gvr := range []schema.GroupVersionResource{
+			parentGVR, v2GVR,
+			parentGVR.GroupVersion().WithResource(parentGVR.Resource + "/eviction"),
+			v2GVR.GroupVersion().WithResource(v2GVR.Resource + "/eviction"),
+		} {
+			delete(apiserver.APIs, gvr)
+		}
+	}()
+
+	t.Run("subresource should be registered for every version of the parent", func(t *testing.T) {
+		a := &Server{storageProvider: map[schema.GroupResource]*singletonProvider{}}
+		// subresource registered before the parent
+		a.WithSubResourceAndStorageProvider("eviction", parent, nil, subProvider)
+		a.forGroupVersionResource(parentGVR, parentProvider)
+		a.forGroupVersionResource(v2GVR, parentProvider)
+		a.registerSubResources()
+		assert.Empty(t, a.errs)
+
+		for _, gv := range []schema.GroupVersion{parentGVR.GroupVersion(), v2GVR.GroupVersion()} {
+			provider, found := apiserver.APIs[gv.WithResource(parentGVR.Resource+"/eviction")]
+			if !assert.True(t, found, "subresource should be registered for %v", gv) {
+				continue
+			}
+			scheme := runtime.NewScheme()
+			scheme.AddKnownTypes(gv, &testEviction{})
+			storage, err := provider(scheme, nil)
+			assert.NoError(t, err)
+			_, ok := storage.(registryrest.NamedCreater)
+			assert.True(t, ok, "subresource storage should be plumbed with the parent storage")
+		}
+	})
+	t.Run("subresource without parent should fail", func(t *testing.T) {
+		a := &Server{storageProvider: map[schema.GroupResource]*singletonProvider{}}
+		a.WithSubResourceAndStorageProvider("eviction", parent, nil, subProvider)
+		a.registerSubResources()
+		assert.Len(t, a.errs, 1)
+	})
+}
+
+func TestWithResourceScaleSubResourcePaths(t *testing.T) {
+	gvr := (&testScaleResource{}).GetGroupVersionResource()
+	scaleGVR := gvr.GroupVersion().WithResource(gvr.Resource + "/scale")
+	defer delete(apiserver.APIs, scaleGVR)
+	provider := func(*runtime.Scheme, generic.RESTOptionsGetter) (registryrest.Storage, error) {
+		return &testParentStorage{}, nil
+	}
+
+	t.Run("valid paths should register the scale subresource", func(t *testing.T) {
+		a := &Server{}
+		a.withSubResourceIfExists(&testScaleResource{}, provider)
+		assert.Empty(t, a.errs)
+		assert.Contains(t, apiserver.APIs, scaleGVR)
+		delete(apiserver.APIs, scaleGVR)
+	})
+	t.Run("invalid paths should fail the build", func(t *testing.T) {
+		a := &Server{}
+		a.withSubResourceIfExists(&testInvalidScaleResource{}, provider)
+		assert.Len(t, a.errs, 1)
+		assert.NotContains(t, apiserver.APIs, scaleGVR)
+	})
+}
+
+type testInvalidScaleResource struct {
+	testScaleResource
+}
+
+func (t *testInvalidScaleResource) GetScaleSubResourcePaths() resource.ScaleSubResourcePaths {
+	return resource.ScaleSubResourcePaths{SpecReplicasPath: ".status.replicas"}
+}
@@ -56,7 +56,7 @@ func AddToScheme(objs ...Object) func(s *runtime.Scheme) error {
 					s.AddKnownTypes(obj.GetGroupVersionResource().GroupVersion(), statusObj)
 				}
 			}
-			if _, ok := obj.(ObjectWithScaleSubResource); ok {
+			if hasScaleSubResource(obj) {
 				if !s.Recognizes(autoscalingv1.SchemeGroupVersion.WithKind("Scale")) {
 					if err := autoscalingv1.AddToScheme(s); err != nil {
 						return err
@@ -77,3 +77,11 @@ func AddToScheme(objs ...Object) func(s *runtime.Scheme) error {
 		return nil
 	}
 }
+
+func hasScaleSubResource(obj Object) bool {
+	if _, ok := obj.(ObjectWithScaleSubResource); ok {
+		return true
+	}
+	_, ok := obj.(ObjectWithScaleSubResourcePaths)
+	return ok
+}
@@ -30,6 +30,12 @@ type CategoriesProvider = rest.CategoriesProvider
 // Required for `kubectl apply`.
 type Creator = rest.Creater
 
+// NamedCreater if implemented will expose POST endpoints for subresources and publish them in the Kubernetes
+// discovery service and OpenAPI.  The name of the parent resource is passed to Create.
+//
+// Required by action subresources such as "eviction" or "binding".
+type NamedCreater = rest.NamedCreater
+
 // CollectionDeleter if implemented will expose DELETE endpoints for resource collections and publish them in
 // the Kubernetes discovery service and OpenAPI.
 //
@@ -98,3 +98,26 @@ type Validater interface {
 type ValidateUpdater interface {
 	ValidateUpdate(ctx context.Context, obj runtime.Object) field.ErrorList
 }
+
+// StatusPrepareForUpdater functions are invoked before an object is stored during an update of the status
+// subresource.  If PrepareForStatusUpdate is implemented for a type, it will be invoked after the status from the
+// request has been copied onto the stored object.  PrepareForUpdate is not invoked for status updates.
+//
+// StatusPrepareForUpdater is only invoked when storing an object and only for the type that is the storage version type.
+type StatusPrepareForUpdater interface {
+	PrepareForStatusUpdate(ctx context.Context, old runtime.Object)
+}
+
+// StatusValidateUpdater functions are invoked before an object is stored to validate the object during an update
+// of the status subresource.  If ValidateStatusUpdate is implemented for a type, it will be invoked instead of
+// ValidateUpdate when updating the status of an object of that type.
+type StatusValidateUpdater interface {
+	ValidateStatusUpdate(ctx context.Context, obj runtime.Object) field.ErrorList
+}
+
+// StatusWarningsOnUpdater functions are invoked when updating the status subresource to return warnings to the
+// client.  If StatusWarningsOnUpdate is implemented for a type, the warnings will be sent as warning headers
+// in the response of a status update.
+type StatusWarningsOnUpdater interface {
+	StatusWarningsOnUpdate(ctx context.Context, old runtime.Object) []string
+}
@@ -55,6 +55,20 @@ type GetterUpdaterSubResource interface {
 	resourcerest.Updater
 }
 
+// CreaterSubResource defines required methods for implementing a subresource that accepts a POST of a request
+// object, e.g. the "eviction" or "binding" subresources of pods.
+//
+// New returns the request object, which may be of a different kind than the parent resource.  The kind will be
+// registered under the parent's group version and published in discovery, unless the subresource implements
+// rest.GroupVersionKindProvider to publish a kind from another group.  The parent storage is plumbed in the
+// context passed to Create and may be retrieved using the "apiserver-runtime/pkg/util/context" package.
+//
+// The subresource may also implement resourcerest.Getter and resourcerest.Updater to serve GET and PUT.
+type CreaterSubResource interface {
+	ArbitrarySubResource
+	resourcerest.NamedCreater
+}
+
 // QueryParameterObject allows the object to be casted to url.Values.
 // It's specifically for Connector subresource.
 type QueryParameterObject interface {
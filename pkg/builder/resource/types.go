@@ -90,6 +90,27 @@ type ObjectWithScaleSubResource interface {
 	GetScale() (scaleSubResource *autoscalingv1.Scale)
 }
 
+// ObjectWithScaleSubResourcePaths defines an interface for declaring the scale sub-resource for a resource through
+// field paths rather than implementing GetScale and SetScale, similar to the scale subresource of a
+// CustomResourceDefinition.  The paths are validated when the resource is registered, and the apiserver fails to
+// build if they are invalid.
+type ObjectWithScaleSubResourcePaths interface {
+	Object
+	GetScaleSubResourcePaths() ScaleSubResourcePaths
+}
+
+// ScaleSubResourcePaths defines the JSON paths, e.g. ".spec.replicas", of the fields backing the scale sub-resource.
+type ScaleSubResourcePaths struct {
+	// SpecReplicasPath is the path of the desired replicas, which must be an integer field under ".spec".
+	SpecReplicasPath string
+	// StatusReplicasPath is the path of the observed replicas, which must be an integer field under ".status".
+	// The observed replicas default to 0 if the field is unset.
+	StatusReplicasPath string
+	// LabelSelectorPath is the optional path of the label selector in its serialized string form, which must be
+	// a string field under ".status" or ".spec".  It populates the selector used by the HorizontalPodAutoscaler.
+	LabelSelectorPath string
+}
+
 // ObjectWithArbitrarySubResource defines an interface for plumbing arbitrary sub-resources for a resource.
 type ObjectWithArbitrarySubResource interface {
 	Object
@@ -111,6 +111,13 @@ func SelectableFields(obj *metav1.ObjectMeta) fields.Set {
 	return generic.ObjectMetaFieldsSet(obj, true)
 }
 
+// ExternalStorage is implemented by the request handlers serving objects stored by another server -- e.g. the
+// kube-apiserver -- which the storage version migration of the apiserver doesn't rewrite.
+type ExternalStorage interface {
+	// IsExternalStorage returns true if the objects are stored by another server.
+	IsExternalStorage() bool
+}
+
 // SubResourceStorageFn is a function that returns objects required to register a subresource into an apiserver
 // path is the subresource path from the parent (e.g. "scale"), parent is the resource the subresource
 // is under (e.g. &v1.Deployment{}), request is the subresource request (e.g. &Scale{}), storage is
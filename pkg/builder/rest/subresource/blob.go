@@ -0,0 +1,222 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subresource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/registry/rest"
+
+	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource"
+)
+
+// NewBlobSubResource returns a subresource named name storing raw binary content for each parent object in store.
+//
+// GET and HEAD download the content, with support for Range requests.  PUT and POST upload the content from the
+// request body, replacing any previous content, and DELETE removes it.
+func NewBlobSubResource(name string, parent resource.Object, store BlobStore) *BlobSubResource {
+	return &BlobSubResource{
+		Name:   name,
+		Parent: parent,
+		Store:  store,
+	}
+}
+
+var _ resource.ConnectorSubResource = &BlobSubResource{}
+
+// BlobSubResource uploads and downloads raw binary content attached to the parent object.  The content is stored
+// under the key "<parent uid>/<subresource name>", so it is not shared with a new object reusing the same name.
+// The content is not removed from the store when the parent object is deleted.
+type BlobSubResource struct {
+	// Name is the name of the subresource.
+	Name string
+	// Parent is the resource the subresource belongs to.
+	Parent resource.Object
+	// Store keeps the uploaded content.
+	Store BlobStore
+	// MaxSize is the maximum size in bytes of uploaded content, 0 means unlimited.
+	MaxSize int64
+	// ContentTypes are the media types accepted for uploads -- e.g. "application/gzip" or "image/*".  If empty, any
+	// content type is accepted.
+	ContentTypes []string
+	// ReadOnly disables uploads and deletes, the content is expected to be written to the store by a controller.
+	ReadOnly bool
+}
+
+func (b *BlobSubResource) SubResourceName() string {
+	return b.Name
+}
+
+func (b *BlobSubResource) New() runtime.Object {
+	return b.Parent.New()
+}
+
+func (b *BlobSubResource) Destroy() {}
+
+func (b *BlobSubResource) NewConnectOptions() (runtime.Object, bool, string) {
+	return nil, false, ""
+}
+
+func (b *BlobSubResource) ConnectMethods() []string {
+	if b.ReadOnly {
+		return []string{http.MethodGet, http.MethodHead}
+	}
+	return []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPost, http.MethodDelete}
+}
+
+func (b *BlobSubResource) Connect(ctx context.Context, id string, _ runtime.Object, r rest.Responder) (http.Handler, error) {
+	parent, err := getParent(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	accessor, err := meta.Accessor(parent)
+	if err != nil {
+		return nil, err
+	}
+	if accessor.GetUID() == "" {
+		return nil, apierrors.NewInternalError(fmt.Errorf("%s %q has no uid", b.groupResource(), id))
+	}
+	key := string(accessor.GetUID()) + "/" + b.Name
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet, http.MethodHead:
+			b.download(w, req, r, id, key)
+		case http.MethodDelete:
+			if err := b.Store.Delete(req.Context(), key); err != nil {
+				r.Error(err)
+				return
+			}
+			r.Object(http.StatusOK, &metav1.Status{Status: metav1.StatusSuccess, Code: http.StatusOK})
+		default:
+			b.upload(req, r, key)
+		}
+	}), nil
+}
+
+func (b *BlobSubResource) download(w http.ResponseWriter, req *http.Request, r rest.Responder, id, key string) {
+	content, info, err := b.Store.Get(req.Context(), key)
+	if errors.Is(err, fs.ErrNotExist) {
+		r.Error(apierrors.NewNotFound(b.groupResource(), id))
+		return
+	}
+	if err != nil {
+		r.Error(err)
+		return
+	}
+	defer content.Close()
+
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	// ServeContent handles Range, If-Modified-Since and HEAD requests
+	http.ServeContent(w, req, "", info.ModTime, content)
+}
+
+func (b *BlobSubResource) upload(req *http.Request, r rest.Responder, key string) {
+	contentType := req.Header.Get("Content-Type")
+	if !b.acceptsContentType(contentType) {
+		r.Error(&apierrors.StatusError{ErrStatus: metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusUnsupportedMediaType,
+			Reason:  metav1.StatusReasonUnsupportedMediaType,
+			Message: fmt.Sprintf("content type %q is not supported, expected one of %v", contentType, b.ContentTypes),
+		}})
+		return
+	}
+	if b.MaxSize > 0 && req.ContentLength > b.MaxSize {
+		r.Error(b.tooLarge())
+		return
+	}
+
+	var body io.Reader = req.Body
+	if b.MaxSize > 0 {
+		body = &maxSizeReader{r: req.Body, remaining: b.MaxSize}
+	}
+	if err := b.Store.Put(req.Context(), key, contentType, body); err != nil {
+		if errors.Is(err, errMaxSizeExceeded) {
+			err = b.tooLarge()
+		}
+		r.Error(err)
+		return
+	}
+	r.Object(http.StatusOK, &metav1.Status{Status: metav1.StatusSuccess, Code: http.StatusOK})
+}
+
+func (b *BlobSubResource) acceptsContentType(contentType string) bool {
+	if len(b.ContentTypes) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, accepted := range b.ContentTypes {
+		if accepted == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(accepted, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *BlobSubResource) tooLarge() error {
+	return apierrors.NewRequestEntityTooLargeError(fmt.Sprintf("content exceeds the maximum size of %d bytes", b.MaxSize))
+}
+
+func (b *BlobSubResource) groupResource() schema.GroupResource {
+	gr := b.Parent.GetGroupVersionResource().GroupResource()
+	gr.Resource += "/" + b.Name
+	return gr
+}
+
+var errMaxSizeExceeded = errors.New("maximum size exceeded")
+
+// maxSizeReader fails reads once more than remaining bytes have been read, so that the store discards the
+// partial content.
+type maxSizeReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (m *maxSizeReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > m.remaining+1 {
+		p = p[:m.remaining+1]
+	}
+	n, err := m.r.Read(p)
+	m.remaining -= int64(n)
+	if m.remaining < 0 {
+		return 0, errMaxSizeExceeded
+	}
+	return n, err
+}
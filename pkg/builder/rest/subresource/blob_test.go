@@ -0,0 +1,113 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subresource
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestBlobSubResource(t *testing.T) {
+	parent := &testResource{ObjectMeta: metav1.ObjectMeta{Name: "foo", UID: "1234"}}
+	sub := NewBlobSubResource("bundle", &testResource{}, NewFilesystemBlobStore(t.TempDir()))
+	sub.MaxSize = 10
+	sub.ContentTypes = []string{"text/*"}
+
+	serve := func(req *http.Request) (*httptest.ResponseRecorder, *testResponder) {
+		r := &testResponder{}
+		handler, err := sub.Connect(withParent(context.TODO(), parent), "foo", nil, r)
+		assert.NoError(t, err)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w, r
+	}
+	upload := func(body, contentType string) *testResponder {
+		req := httptest.NewRequest(http.MethodPut, "/bundle", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		_, r := serve(req)
+		return r
+	}
+
+	t.Run("missing content should not be found", func(t *testing.T) {
+		_, r := serve(httptest.NewRequest(http.MethodGet, "/bundle", nil))
+		assert.True(t, apierrors.IsNotFound(r.err))
+	})
+	t.Run("uploaded content should be downloaded", func(t *testing.T) {
+		r := upload("0123456789", "text/plain; charset=utf-8")
+		assert.NoError(t, r.err)
+		assert.Equal(t, http.StatusOK, r.code)
+
+		w, r := serve(httptest.NewRequest(http.MethodGet, "/bundle", nil))
+		assert.NoError(t, r.err)
+		assert.Equal(t, "0123456789", w.Body.String())
+		assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	})
+	t.Run("range requests should return part of the content", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/bundle", nil)
+		req.Header.Set("Range", "bytes=2-4")
+		w, _ := serve(req)
+		assert.Equal(t, http.StatusPartialContent, w.Code)
+		assert.Equal(t, "234", w.Body.String())
+	})
+	t.Run("content larger than the maximum size should be rejected", func(t *testing.T) {
+		r := upload("0123456789a", "text/plain")
+		assert.True(t, apierrors.IsRequestEntityTooLargeError(r.err))
+
+		// without content length the body is only checked while streamed
+		req := httptest.NewRequest(http.MethodPut, "/bundle", strings.NewReader("abcdefghijk"))
+		req.ContentLength = -1
+		req.Header.Set("Content-Type", "text/plain")
+		_, r = serve(req)
+		assert.True(t, apierrors.IsRequestEntityTooLargeError(r.err))
+
+		w, _ := serve(httptest.NewRequest(http.MethodGet, "/bundle", nil))
+		assert.Equal(t, "0123456789", w.Body.String(), "previous content should be kept")
+	})
+	t.Run("unsupported content types should be rejected", func(t *testing.T) {
+		r := upload("{}", "application/json")
+		assert.Equal(t, metav1.StatusReasonUnsupportedMediaType, apierrors.ReasonForError(r.err))
+	})
+	t.Run("deleted content should not be found", func(t *testing.T) {
+		_, r := serve(httptest.NewRequest(http.MethodDelete, "/bundle", nil))
+		assert.NoError(t, r.err)
+		_, r = serve(httptest.NewRequest(http.MethodGet, "/bundle", nil))
+		assert.True(t, apierrors.IsNotFound(r.err))
+	})
+}
+
+type testResponder struct {
+	code int
+	obj  runtime.Object
+	err  error
+}
+
+func (t *testResponder) Object(statusCode int, obj runtime.Object) {
+	t.code = statusCode
+	t.obj = obj
+}
+
+func (t *testResponder) Error(err error) {
+	t.err = err
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subresource
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// BlobInfo describes a stored blob.
+type BlobInfo struct {
+	// Size is the size of the blob in bytes.
+	Size int64
+	// ContentType is the content type the blob was uploaded with.
+	ContentType string
+	// ModTime is the time the blob was last uploaded.
+	ModTime time.Time
+}
+
+// BlobStore stores the content of binary subresources.  Keys are slash separated paths starting with the UID of
+// the parent object.
+type BlobStore interface {
+	// Put stores the content read from r under key, replacing any existing blob.  If reading from r fails, the
+	// existing blob must be kept.
+	Put(ctx context.Context, key, contentType string, r io.Reader) error
+	// Get opens the blob stored under key.  The returned error wraps fs.ErrNotExist if there is no such blob.
+	Get(ctx context.Context, key string) (io.ReadSeekCloser, *BlobInfo, error)
+	// Delete removes the blob stored under key.  Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// NewFilesystemBlobStore returns a BlobStore keeping blobs as files under root.
+func NewFilesystemBlobStore(root string) *FilesystemBlobStore {
+	return &FilesystemBlobStore{root: root}
+}
+
+var _ BlobStore = &FilesystemBlobStore{}
+
+// FilesystemBlobStore keeps each blob in a file named after its key, next to a ".meta" file holding the content
+// type.
+type FilesystemBlobStore struct {
+	root string
+}
+
+type blobMeta struct {
+	ContentType string `json:"contentType,omitempty"`
+}
+
+func (f *FilesystemBlobStore) Put(_ context.Context, key, contentType string, r io.Reader) error {
+	p, err := f.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	meta, err := json.Marshal(blobMeta{ContentType: contentType})
+	if err != nil {
+		return err
+	}
+	// both files are written before either replaces the previous blob, so that a failed upload keeps it
+	content, err := writeTemp(p, r)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(content)
+	metaContent, err := writeTemp(p+".meta", bytes.NewReader(meta))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(metaContent)
+	// the content type of the previous blob must not be reported for the new content: the ".meta" file is removed
+	// first and committed last, readers observing no ".meta" file report no content type
+	if err := os.Remove(p + ".meta"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Rename(content, p); err != nil {
+		return err
+	}
+	return os.Rename(metaContent, p+".meta")
+}
+
+func (f *FilesystemBlobStore) Get(_ context.Context, key string) (io.ReadSeekCloser, *BlobInfo, error) {
+	p, err := f.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := os.Open(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	info := &BlobInfo{Size: stat.Size(), ModTime: stat.ModTime()}
+	// the ".meta" file is missing while a blob is being replaced, the content type is unknown then
+	b, err := os.ReadFile(p + ".meta")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		file.Close()
+		return nil, nil, err
+	}
+	if err == nil {
+		meta := blobMeta{}
+		if err := json.Unmarshal(b, &meta); err == nil {
+			info.ContentType = meta.ContentType
+		}
+	}
+	return file, info, nil
+}
+
+func (f *FilesystemBlobStore) Delete(_ context.Context, key string) error {
+	p, err := f.path(key)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{p, p + ".meta"} {
+		if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// path returns the file of the blob, refusing keys escaping the root.
+func (f *FilesystemBlobStore) path(key string) (string, error) {
+	p := filepath.FromSlash(key)
+	if !filepath.IsLocal(p) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(f.root, p), nil
+}
+
+// writeTemp writes the content of r to a temporary file next to name, to be renamed to name once complete so that
+// readers never observe a partial file.  The caller removes the temporary file if it isn't renamed.
+func writeTemp(name string, r io.Reader) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subresource
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failingReader returns its content, then fails.
+type failingReader struct {
+	r io.Reader
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF {
+		return n, errors.New("failed")
+	}
+	return n, err
+}
+
+func TestFilesystemBlobStore(t *testing.T) {
+	root := t.TempDir()
+	store := NewFilesystemBlobStore(root)
+	read := func(t *testing.T) (string, *BlobInfo) {
+		content, info, err := store.Get(context.TODO(), "1234/bundle")
+		if !assert.NoError(t, err) {
+			return "", nil
+		}
+		defer content.Close()
+		b, err := io.ReadAll(content)
+		assert.NoError(t, err)
+		return string(b), info
+	}
+
+	assert.NoError(t, store.Put(context.TODO(), "1234/bundle", "text/plain", strings.NewReader("v1")))
+	content, info := read(t)
+	assert.Equal(t, "v1", content)
+	assert.Equal(t, "text/plain", info.ContentType)
+
+	t.Run("failed uploads should keep the previous blob", func(t *testing.T) {
+		err := store.Put(context.TODO(), "1234/bundle", "text/csv", &failingReader{r: strings.NewReader("v2")})
+		assert.Error(t, err)
+		content, info := read(t)
+		assert.Equal(t, "v1", content)
+		assert.Equal(t, "text/plain", info.ContentType)
+		entries, err := os.ReadDir(filepath.Join(root, "1234"))
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2, "temporary files should be removed")
+	})
+	t.Run("blobs without a meta file should have no content type", func(t *testing.T) {
+		assert.NoError(t, os.Remove(filepath.Join(root, "1234", "bundle.meta")))
+		content, info := read(t)
+		assert.Equal(t, "v1", content)
+		assert.Equal(t, "", info.ContentType)
+	})
+	t.Run("keys escaping the root should be rejected", func(t *testing.T) {
+		assert.Error(t, store.Put(context.TODO(), "../bundle", "text/plain", strings.NewReader("v1")))
+	})
+}
@@ -0,0 +1,253 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subresource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/httpstream/wsstream"
+	"k8s.io/apimachinery/pkg/util/remotecommand"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource"
+)
+
+// ExecOptions are the query parameters accepted by the exec and attach subresources -- e.g.
+// ".../NAME/exec?command=sh&stdin=true&stdout=true&tty=true".
+type ExecOptions struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Stdin redirects the standard input stream of the client to the session.
+	Stdin bool `json:"stdin,omitempty"`
+	// Stdout redirects the output of the session to the client.
+	Stdout bool `json:"stdout,omitempty"`
+	// Stderr is accepted for compatibility with kubectl.  Sessions only have a single output stream which is
+	// sent as stdout.
+	Stderr bool `json:"stderr,omitempty"`
+	// TTY indicates the client allocated a terminal and may send resize events.
+	TTY bool `json:"tty,omitempty"`
+	// Command is the command to execute.  Always empty for attach.
+	Command []string `json:"command,omitempty"`
+}
+
+var _ resource.QueryParameterObject = &ExecOptions{}
+
+// ConvertFromUrlValues parses the query parameters of an exec or attach request.
+func (o *ExecOptions) ConvertFromUrlValues(values *url.Values) error {
+	var err error
+	if o.Stdin, err = parseBool(values, "stdin"); err != nil {
+		return err
+	}
+	if o.Stdout, err = parseBool(values, "stdout"); err != nil {
+		return err
+	}
+	if o.Stderr, err = parseBool(values, "stderr"); err != nil {
+		return err
+	}
+	if o.TTY, err = parseBool(values, "tty"); err != nil {
+		return err
+	}
+	o.Command = (*values)["command"]
+	return nil
+}
+
+// DeepCopyObject implements runtime.Object.
+func (o *ExecOptions) DeepCopyObject() runtime.Object {
+	out := *o
+	out.Command = append([]string(nil), o.Command...)
+	return &out
+}
+
+// ExecSessionFunc starts an exec or attach session for the parent object.  Bytes sent by the client on stdin
+// are written to the returned session, and bytes read from the session are sent to the client on stdout.  The
+// session ends when reading from it returns io.EOF, and it is closed when the session ends if it implements
+// io.Closer.  If reading returns another error, the error is reported to the client.
+//
+// If the session implements TerminalResizer, resize events sent by the client are passed to it.
+type ExecSessionFunc func(ctx context.Context, parent runtime.Object, options *ExecOptions) (io.ReadWriter, error)
+
+// TerminalResizer may be implemented by the session returned from an ExecSessionFunc to receive terminal resize
+// events.
+type TerminalResizer interface {
+	Resize(width, height uint16) error
+}
+
+// NewExecSubResource returns an "exec" subresource serving sessions started by fn over the WebSocket channel
+// protocol used by "kubectl exec", versions v4.channel.k8s.io and v5.channel.k8s.io.
+func NewExecSubResource(parent resource.Object, fn ExecSessionFunc) resource.ConnectorSubResource {
+	return &execSubResource{name: "exec", parent: parent, fn: fn}
+}
+
+// NewAttachSubResource returns an "attach" subresource serving sessions started by fn over the WebSocket channel
+// protocol used by "kubectl attach", versions v4.channel.k8s.io and v5.channel.k8s.io.
+func NewAttachSubResource(parent resource.Object, fn ExecSessionFunc) resource.ConnectorSubResource {
+	return &execSubResource{name: "attach", parent: parent, fn: fn}
+}
+
+var _ resource.ConnectorSubResource = &execSubResource{}
+
+type execSubResource struct {
+	name   string
+	parent resource.Object
+	fn     ExecSessionFunc
+}
+
+func (e *execSubResource) SubResourceName() string {
+	return e.name
+}
+
+func (e *execSubResource) New() runtime.Object {
+	return e.parent.New()
+}
+
+func (e *execSubResource) Destroy() {}
+
+func (e *execSubResource) NewConnectOptions() (runtime.Object, bool, string) {
+	return &ExecOptions{}, false, ""
+}
+
+func (e *execSubResource) ConnectMethods() []string {
+	// WebSocket upgrades are GET requests, the SPDY upgrades of older clients -- POST requests -- are not supported.
+	return []string{http.MethodGet}
+}
+
+func (e *execSubResource) Connect(ctx context.Context, id string, options runtime.Object, r rest.Responder) (http.Handler, error) {
+	opts, ok := options.(*ExecOptions)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid options object: %#v", options))
+	}
+	parent, err := getParent(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !wsstream.IsWebSocketRequest(req) {
+			http.Error(w, "only the WebSocket channel protocols "+remotecommand.StreamProtocolV5Name+
+				" and "+remotecommand.StreamProtocolV4Name+" are supported", http.StatusBadRequest)
+			return
+		}
+		session, err := e.fn(req.Context(), parent, opts)
+		if err != nil {
+			r.Error(err)
+			return
+		}
+		serveSession(w, req, opts, session)
+	}), nil
+}
+
+// serveSession copies the streams of the WebSocket connection to and from the session until the session ends.
+func serveSession(w http.ResponseWriter, req *http.Request, opts *ExecOptions, session io.ReadWriter) {
+	if c, ok := session.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	channels := []wsstream.ChannelType{
+		remotecommand.StreamStdIn:  wsstream.IgnoreChannel,
+		remotecommand.StreamStdOut: wsstream.IgnoreChannel,
+		remotecommand.StreamStdErr: wsstream.IgnoreChannel,
+		remotecommand.StreamErr:    wsstream.WriteChannel,
+		remotecommand.StreamResize: wsstream.IgnoreChannel,
+	}
+	if opts.Stdin {
+		channels[remotecommand.StreamStdIn] = wsstream.ReadChannel
+	}
+	if opts.Stdout {
+		channels[remotecommand.StreamStdOut] = wsstream.WriteChannel
+	}
+	if opts.Stderr {
+		channels[remotecommand.StreamStdErr] = wsstream.WriteChannel
+	}
+	if opts.TTY {
+		channels[remotecommand.StreamResize] = wsstream.ReadChannel
+	}
+	conn := wsstream.NewConn(map[string]wsstream.ChannelProtocolConfig{
+		remotecommand.StreamProtocolV5Name: {Binary: true, Channels: channels},
+		remotecommand.StreamProtocolV4Name: {Binary: true, Channels: channels},
+	})
+	_, streams, err := conn.Open(w, req)
+	if err != nil {
+		klog.Errorf("unable to upgrade exec session: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	if opts.Stdin {
+		go func() {
+			_, _ = io.Copy(session, streams[remotecommand.StreamStdIn])
+			// stdin was closed by the client, propagate if the session supports it
+			if c, ok := session.(interface{ CloseWrite() error }); ok {
+				_ = c.CloseWrite()
+			}
+		}()
+	}
+	if resizer, ok := session.(TerminalResizer); ok && opts.TTY {
+		go handleResize(streams[remotecommand.StreamResize], resizer)
+	}
+
+	out := io.Discard
+	if opts.Stdout {
+		out = streams[remotecommand.StreamStdOut]
+	}
+	_, err = io.Copy(out, session)
+	writeStatus(streams[remotecommand.StreamErr], err)
+}
+
+// handleResize decodes resize events until the stream is closed.
+func handleResize(stream io.Reader, resizer TerminalResizer) {
+	decoder := json.NewDecoder(stream)
+	for {
+		size := struct {
+			Width  uint16
+			Height uint16
+		}{}
+		if err := decoder.Decode(&size); err != nil {
+			return
+		}
+		if err := resizer.Resize(size.Width, size.Height); err != nil {
+			klog.Errorf("unable to resize exec session terminal: %v", err)
+		}
+	}
+}
+
+// writeStatus reports the result of the session on the error channel.
+func writeStatus(stream io.Writer, err error) {
+	status := metav1.Status{Status: metav1.StatusSuccess}
+	if err != nil {
+		status = metav1.Status{
+			Status:  metav1.StatusFailure,
+			Reason:  metav1.StatusReasonInternalError,
+			Message: err.Error(),
+		}
+	}
+	status.Kind = "Status"
+	status.APIVersion = "v1"
+	b, err := json.Marshal(status)
+	if err != nil {
+		klog.Errorf("unable to encode exec session status: %v", err)
+		return
+	}
+	_, _ = stream.Write(b)
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subresource
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/remotecommand"
+)
+
+func TestExecSubResource(t *testing.T) {
+	parent := &testResource{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}
+	var command []string
+	sub := NewExecSubResource(&testResource{}, func(_ context.Context, _ runtime.Object, opts *ExecOptions) (io.ReadWriter, error) {
+		command = opts.Command
+		r, w := io.Pipe()
+		return &echoSession{r: r, w: w}, nil
+	})
+	opts := &ExecOptions{Stdin: true, Stdout: true, Command: []string{"cat"}}
+	handler, err := sub.Connect(withParent(context.TODO(), parent), "foo", opts, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	t.Run("invalid options should be bad requests", func(t *testing.T) {
+		values := url.Values{"stdin": []string{"maybe"}}
+		assert.True(t, apierrors.IsBadRequest((&ExecOptions{}).ConvertFromUrlValues(&values)))
+		_, err := sub.Connect(withParent(context.TODO(), parent), "foo", &LogOptions{}, nil)
+		assert.True(t, apierrors.IsBadRequest(err))
+	})
+	t.Run("non websocket requests should be rejected", func(t *testing.T) {
+		resp, err := http.Get(server.URL)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+	t.Run("stdin should be streamed to the session and the session to stdout", func(t *testing.T) {
+		config, err := websocket.NewConfig("ws"+strings.TrimPrefix(server.URL, "http"), server.URL)
+		assert.NoError(t, err)
+		config.Protocol = []string{remotecommand.StreamProtocolV5Name}
+		ws, err := websocket.DialConfig(config)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer ws.Close()
+
+		assert.NoError(t, websocket.Message.Send(ws, append([]byte{remotecommand.StreamStdIn}, "hello"...)))
+		var frame []byte
+		assert.NoError(t, websocket.Message.Receive(ws, &frame))
+		assert.Equal(t, append([]byte{remotecommand.StreamStdOut}, "hello"...), frame)
+
+		// close stdin, ending the session
+		assert.NoError(t, websocket.Message.Send(ws, []byte{255, remotecommand.StreamStdIn}))
+		assert.NoError(t, websocket.Message.Receive(ws, &frame))
+		assert.Equal(t, byte(remotecommand.StreamErr), frame[0])
+		status := metav1.Status{}
+		assert.NoError(t, json.Unmarshal(frame[1:], &status))
+		assert.Equal(t, metav1.StatusSuccess, status.Status)
+		assert.Equal(t, []string{"cat"}, command)
+	})
+}
+
+// echoSession returns everything written to it.
+type echoSession struct {
+	r *io.PipeReader
+	w *io.PipeWriter
+}
+
+func (e *echoSession) Read(p []byte) (int, error) {
+	return e.r.Read(p)
+}
+
+func (e *echoSession) Write(p []byte) (int, error) {
+	return e.w.Write(p)
+}
+
+func (e *echoSession) CloseWrite() error {
+	return e.w.Close()
+}
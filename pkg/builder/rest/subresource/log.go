@@ -0,0 +1,209 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subresource
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/registry/rest"
+
+	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource"
+)
+
+// LogOptions are the query parameters accepted by the log subresource -- e.g.
+// ".../NAME/log?follow=true&tailLines=10".
+type LogOptions struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Follow keeps the stream open and sends new log lines as they are written.
+	Follow bool `json:"follow,omitempty"`
+	// TailLines limits the logs to the given number of lines from the end.
+	TailLines *int64 `json:"tailLines,omitempty"`
+	// LimitBytes limits the number of bytes sent to the client.
+	LimitBytes *int64 `json:"limitBytes,omitempty"`
+}
+
+var _ resource.QueryParameterObject = &LogOptions{}
+
+// ConvertFromUrlValues parses the query parameters of a log request.
+func (o *LogOptions) ConvertFromUrlValues(values *url.Values) error {
+	var err error
+	if o.Follow, err = parseBool(values, "follow"); err != nil {
+		return err
+	}
+	if o.TailLines, err = parseInt64(values, "tailLines"); err != nil {
+		return err
+	}
+	if o.LimitBytes, err = parseInt64(values, "limitBytes"); err != nil {
+		return err
+	}
+	if o.TailLines != nil && *o.TailLines < 0 {
+		return apierrors.NewBadRequest("tailLines must be greater than or equal to 0")
+	}
+	if o.LimitBytes != nil && *o.LimitBytes < 1 {
+		return apierrors.NewBadRequest("limitBytes must be greater than 0")
+	}
+	return nil
+}
+
+// DeepCopyObject implements runtime.Object.
+func (o *LogOptions) DeepCopyObject() runtime.Object {
+	out := *o
+	if o.TailLines != nil {
+		v := *o.TailLines
+		out.TailLines = &v
+	}
+	if o.LimitBytes != nil {
+		v := *o.LimitBytes
+		out.LimitBytes = &v
+	}
+	return &out
+}
+
+// LogStreamFunc returns the logs of the parent object.
+//
+// If options.Follow is set, the returned stream should keep returning new log lines until it is closed, which
+// happens when the client disconnects.  If options.TailLines is set while following, the stream should start from
+// the given number of lines from the end.  Without following, the tail of the stream is taken automatically.
+type LogStreamFunc func(ctx context.Context, parent runtime.Object, options *LogOptions) (io.ReadCloser, error)
+
+// NewLogSubResource returns a "log" subresource streaming the logs returned by fn as chunked plain text,
+// similar to "kubectl logs".
+func NewLogSubResource(parent resource.Object, fn LogStreamFunc) resource.ConnectorSubResource {
+	return &logSubResource{parent: parent, fn: fn}
+}
+
+var _ resource.ConnectorSubResource = &logSubResource{}
+
+type logSubResource struct {
+	parent resource.Object
+	fn     LogStreamFunc
+}
+
+func (l *logSubResource) SubResourceName() string {
+	return "log"
+}
+
+func (l *logSubResource) New() runtime.Object {
+	return l.parent.New()
+}
+
+func (l *logSubResource) Destroy() {}
+
+func (l *logSubResource) NewConnectOptions() (runtime.Object, bool, string) {
+	return &LogOptions{}, false, ""
+}
+
+func (l *logSubResource) ConnectMethods() []string {
+	return []string{http.MethodGet}
+}
+
+func (l *logSubResource) Connect(ctx context.Context, id string, options runtime.Object, r rest.Responder) (http.Handler, error) {
+	opts, ok := options.(*LogOptions)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid options object: %#v", options))
+	}
+	parent, err := getParent(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := l.fn(ctx, parent, opts)
+	if err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer stream.Close()
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			// unblock reads of the stream when the client goes away
+			select {
+			case <-req.Context().Done():
+				_ = stream.Close()
+			case <-done:
+			}
+		}()
+
+		var reader io.Reader = stream
+		if opts.TailLines != nil && !opts.Follow {
+			tailed, err := tail(stream, *opts.TailLines)
+			if err != nil {
+				r.Error(err)
+				return
+			}
+			reader = tailed
+		}
+		if opts.LimitBytes != nil {
+			reader = io.LimitReader(reader, *opts.LimitBytes)
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		if flusher, ok := w.(http.Flusher); ok {
+			// followers may wait for the first line, send the headers now
+			flusher.Flush()
+		}
+		_, _ = io.Copy(&flushWriter{w: w}, reader)
+	}), nil
+}
+
+// tail returns the last n lines of r, which may be of any length.
+func tail(r io.Reader, n int64) (io.Reader, error) {
+	var lines [][]byte
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && n > 0 {
+			if line[len(line)-1] != '\n' {
+				line = append(line, '\n')
+			}
+			if int64(len(lines)) == n {
+				lines = lines[1:]
+			}
+			lines = append(lines, line)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bytes.NewReader(bytes.Join(lines, nil)), nil
+}
+
+// flushWriter flushes every write so that the client receives each chunk as soon as it is read.
+type flushWriter struct {
+	w io.Writer
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if flusher, ok := f.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subresource
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestLogSubResource(t *testing.T) {
+	parent := &testResource{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}
+	var received runtime.Object
+	sub := NewLogSubResource(&testResource{}, func(_ context.Context, p runtime.Object, _ *LogOptions) (io.ReadCloser, error) {
+		received = p
+		return io.NopCloser(strings.NewReader("one\ntwo\nthree\n")), nil
+	})
+
+	serve := func(query string) *httptest.ResponseRecorder {
+		values, err := url.ParseQuery(query)
+		assert.NoError(t, err)
+		opts, _, _ := sub.NewConnectOptions()
+		assert.NoError(t, opts.(*LogOptions).ConvertFromUrlValues(&values))
+		handler, err := sub.Connect(withParent(context.TODO(), parent), "foo", opts, nil)
+		assert.NoError(t, err)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/log?"+query, nil))
+		return w
+	}
+
+	t.Run("logs should be streamed for the parent", func(t *testing.T) {
+		w := serve("")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "one\ntwo\nthree\n", w.Body.String())
+		assert.Same(t, parent, received)
+	})
+	t.Run("tailLines should return the last lines", func(t *testing.T) {
+		assert.Equal(t, "two\nthree\n", serve("tailLines=2").Body.String())
+		assert.Equal(t, "", serve("tailLines=0").Body.String())
+	})
+	t.Run("tailLines should return lines longer than 64KB", func(t *testing.T) {
+		long := strings.Repeat("x", 100*1024)
+		tailed, err := tail(strings.NewReader("one\n"+long+"\nthree"), 2)
+		assert.NoError(t, err)
+		content, err := io.ReadAll(tailed)
+		assert.NoError(t, err)
+		assert.Equal(t, long+"\nthree\n", string(content))
+	})
+	t.Run("limitBytes should truncate the logs", func(t *testing.T) {
+		assert.Equal(t, "one\nt", serve("limitBytes=5").Body.String())
+	})
+	t.Run("invalid options should be bad requests", func(t *testing.T) {
+		for _, query := range []string{"tailLines=-1", "tailLines=x", "limitBytes=0", "follow=maybe"} {
+			values, err := url.ParseQuery(query)
+			assert.NoError(t, err)
+			err = (&LogOptions{}).ConvertFromUrlValues(&values)
+			assert.True(t, apierrors.IsBadRequest(err), "%s: %v", query, err)
+		}
+	})
+}
+
+func TestLogSubResourceFollow(t *testing.T) {
+	parent := &testResource{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}
+	reader, writer := io.Pipe()
+	var follow bool
+	sub := NewLogSubResource(&testResource{}, func(_ context.Context, _ runtime.Object, o *LogOptions) (io.ReadCloser, error) {
+		follow = o.Follow
+		return reader, nil
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		values := req.URL.Query()
+		opts, _, _ := sub.NewConnectOptions()
+		if err := opts.(*LogOptions).ConvertFromUrlValues(&values); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		handler, err := sub.Connect(withParent(req.Context(), parent), "foo", opts, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		handler.ServeHTTP(w, req)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/log?follow=true", nil)
+	assert.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	assert.True(t, follow)
+
+	lines := bufio.NewReader(resp.Body)
+	for _, line := range []string{"one\n", "two\n"} {
+		// each line should be received as soon as it is written
+		_, err := writer.Write([]byte(line))
+		assert.NoError(t, err)
+		received, err := lines.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, line, received)
+	}
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		_, err := writer.Write([]byte("three\n"))
+		return errors.Is(err, io.ErrClosedPipe)
+	}, 5*time.Second, 10*time.Millisecond, "the stream should be closed when the client disconnects")
+}
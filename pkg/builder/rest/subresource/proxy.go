@@ -0,0 +1,196 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subresource
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/proxy"
+	"k8s.io/apiserver/pkg/registry/rest"
+
+	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource"
+)
+
+// ProxyOptions are the parameters of a proxy request.  Path is the remainder of the request path after
+// ".../NAME/proxy/".
+type ProxyOptions struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Path is the path appended to the upstream location.
+	Path string `json:"path,omitempty"`
+}
+
+var _ resource.QueryParameterObject = &ProxyOptions{}
+
+// ConvertFromUrlValues parses the parameters of a proxy request.
+func (o *ProxyOptions) ConvertFromUrlValues(values *url.Values) error {
+	o.Path = values.Get("path")
+	return nil
+}
+
+// DeepCopyObject implements runtime.Object.
+func (o *ProxyOptions) DeepCopyObject() runtime.Object {
+	out := *o
+	return &out
+}
+
+// ProxyLocationFunc returns the upstream location requests to the parent object are proxied to.
+type ProxyLocationFunc func(ctx context.Context, parent runtime.Object) (*url.URL, error)
+
+// NewProxySubResource returns a "proxy" subresource forwarding requests to the location returned by fn, similar
+// to "services/proxy".  Only locations with an http or https scheme and a host matching one of allowedHosts are
+// proxied, see ProxySubResource.AllowedHosts.
+func NewProxySubResource(parent resource.Object, fn ProxyLocationFunc, allowedHosts ...string) *ProxySubResource {
+	return &ProxySubResource{
+		Parent:         parent,
+		Location:       fn,
+		AllowedSchemes: []string{"http", "https"},
+		AllowedHosts:   allowedHosts,
+	}
+}
+
+var _ resource.ConnectorSubResource = &ProxySubResource{}
+
+// ProxySubResource forwards requests of any method to an upstream location derived from the parent object.
+// Upgrade requests (e.g. WebSockets) are proxied as well.
+type ProxySubResource struct {
+	// Parent is the resource the subresource belongs to.
+	Parent resource.Object
+	// Location returns the upstream location for a parent object.
+	Location ProxyLocationFunc
+	// AllowedSchemes are the schemes an upstream location may use.
+	AllowedSchemes []string
+	// AllowedHosts are the hosts an upstream location may point to.  An entry matches either the hostname, or the
+	// host and port, of the location.  Entries starting with "*." match any subdomain.  An empty list forbids
+	// all hosts.
+	AllowedHosts []string
+	// Transport is used to connect to the upstream, http.DefaultTransport is used if nil.
+	Transport http.RoundTripper
+}
+
+func (p *ProxySubResource) SubResourceName() string {
+	return "proxy"
+}
+
+func (p *ProxySubResource) New() runtime.Object {
+	return p.Parent.New()
+}
+
+func (p *ProxySubResource) Destroy() {}
+
+func (p *ProxySubResource) NewConnectOptions() (runtime.Object, bool, string) {
+	return &ProxyOptions{}, true, "path"
+}
+
+func (p *ProxySubResource) ConnectMethods() []string {
+	return []string{
+		http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions,
+	}
+}
+
+func (p *ProxySubResource) Connect(ctx context.Context, id string, options runtime.Object, r rest.Responder) (http.Handler, error) {
+	opts, ok := options.(*ProxyOptions)
+	if !ok {
+		return nil, errors.NewBadRequest(fmt.Sprintf("invalid options object: %#v", options))
+	}
+	parent, err := getParent(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	upstream, err := p.Location(ctx, parent)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.allowed(upstream); err != nil {
+		gr := p.Parent.GetGroupVersionResource().GroupResource()
+		gr.Resource += "/" + p.SubResourceName()
+		return nil, errors.NewForbidden(gr, id, err)
+	}
+
+	location := *upstream
+	location.Path, err = joinPath(upstream.Path, opts.Path)
+	if err != nil {
+		return nil, errors.NewBadRequest(err.Error())
+	}
+	// the raw path of the upstream location no longer matches the path
+	location.RawPath = ""
+	transport := p.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		loc := location
+		// the upgrade path doesn't copy the query from the request
+		loc.RawQuery = req.URL.RawQuery
+		handler := proxy.NewUpgradeAwareHandler(&loc, transport, false, false, proxy.NewErrorResponder(r))
+		// virtual-hosted upstreams route the requests by the host of their location, not of the apiserver
+		handler.UseLocationHost = true
+		handler.ServeHTTP(w, req)
+	}), nil
+}
+
+// allowed returns an error if the upstream location isn't allowed by the scheme and host allowlists.
+func (p *ProxySubResource) allowed(upstream *url.URL) error {
+	schemeAllowed := false
+	for _, s := range p.AllowedSchemes {
+		if strings.EqualFold(s, upstream.Scheme) {
+			schemeAllowed = true
+			break
+		}
+	}
+	if !schemeAllowed {
+		return fmt.Errorf("scheme %q of the upstream location is not allowed", upstream.Scheme)
+	}
+	hostname := strings.ToLower(upstream.Hostname())
+	for _, h := range p.AllowedHosts {
+		h = strings.ToLower(h)
+		switch {
+		case h == hostname, h == strings.ToLower(upstream.Host):
+			return nil
+		case strings.HasPrefix(h, "*.") && strings.HasSuffix(hostname, h[1:]):
+			return nil
+		}
+	}
+	return fmt.Errorf("host %q of the upstream location is not allowed", upstream.Host)
+}
+
+// joinPath appends the requested path to the path of the upstream location, and cleans it.  Requested paths
+// escaping the path of the upstream location, e.g. with "../" segments, are rejected.
+func joinPath(base, requested string) (string, error) {
+	if requested == "" {
+		return base, nil
+	}
+	joined := strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(requested, "/")
+	cleaned := path.Clean("/" + joined)
+	if strings.HasSuffix(joined, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	root := path.Clean("/" + base)
+	if root != "/" && strings.TrimSuffix(cleaned, "/") != root && !strings.HasPrefix(cleaned, root+"/") {
+		return "", fmt.Errorf("path %q is outside of the upstream location", requested)
+	}
+	return cleaned, nil
+}
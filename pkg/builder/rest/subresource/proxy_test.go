@@ -0,0 +1,123 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subresource
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestProxySubResource(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/base/ws" {
+			websocket.Handler(func(ws *websocket.Conn) {
+				_, _ = io.Copy(ws, ws)
+			}).ServeHTTP(w, req)
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		fmt.Fprintf(w, "%s %s?%s %s", req.Method, req.URL.Path, req.URL.RawQuery, body)
+	}))
+	defer upstream.Close()
+
+	location := func(_ context.Context, parent runtime.Object) (*url.URL, error) {
+		return url.Parse(parent.(*testResource).Backend)
+	}
+	connect := func(sub *ProxySubResource, backend, path string) (http.Handler, error) {
+		parent := &testResource{ObjectMeta: metav1.ObjectMeta{Name: "foo"}, Backend: backend}
+		return sub.Connect(withParent(context.TODO(), parent), "foo", &ProxyOptions{Path: path}, nil)
+	}
+
+	t.Run("requests should be forwarded to the upstream of the parent", func(t *testing.T) {
+		handler, err := connect(NewProxySubResource(&testResource{}, location, "127.0.0.1"), upstream.URL+"/base", "a/b")
+		if !assert.NoError(t, err) {
+			return
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/proxy/a/b?x=1", strings.NewReader("data")))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "POST /base/a/b?x=1 data", w.Body.String())
+	})
+	t.Run("requests should have the host of the upstream", func(t *testing.T) {
+		hosts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(w, req.Host)
+		}))
+		defer hosts.Close()
+		handler, err := connect(NewProxySubResource(&testResource{}, location, "127.0.0.1"), hosts.URL, "")
+		if !assert.NoError(t, err) {
+			return
+		}
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/proxy/", nil)
+		req.Host = "apiserver.example.com"
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, strings.TrimPrefix(hosts.URL, "http://"), w.Body.String())
+	})
+	t.Run("upgrade requests should be forwarded to the upstream", func(t *testing.T) {
+		handler, err := connect(NewProxySubResource(&testResource{}, location, "127.0.0.1"), upstream.URL+"/base", "ws")
+		if !assert.NoError(t, err) {
+			return
+		}
+		server := httptest.NewServer(handler)
+		defer server.Close()
+		ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/proxy/ws", "", server.URL)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer ws.Close()
+		assert.NoError(t, websocket.Message.Send(ws, "hello"))
+		var msg string
+		assert.NoError(t, websocket.Message.Receive(ws, &msg))
+		assert.Equal(t, "hello", msg)
+	})
+	t.Run("hosts not in the allowlist should be forbidden", func(t *testing.T) {
+		_, err := connect(NewProxySubResource(&testResource{}, location, "*.example.com"), upstream.URL, "")
+		assert.True(t, errors.IsForbidden(err))
+		_, err = connect(NewProxySubResource(&testResource{}, location, "*.example.com"), "http://backend.example.com", "")
+		assert.NoError(t, err)
+	})
+	t.Run("schemes not in the allowlist should be forbidden", func(t *testing.T) {
+		_, err := connect(NewProxySubResource(&testResource{}, location, "127.0.0.1"), "file:///etc/passwd", "")
+		assert.True(t, errors.IsForbidden(err))
+	})
+	t.Run("paths escaping the upstream path should be rejected", func(t *testing.T) {
+		sub := NewProxySubResource(&testResource{}, location, "127.0.0.1")
+		for _, path := range []string{"../secret", "a/../../secret", "/../base2"} {
+			_, err := connect(sub, upstream.URL+"/base", path)
+			assert.True(t, errors.IsBadRequest(err), path)
+		}
+		handler, err := connect(sub, upstream.URL+"/base", "a/../b/./c/")
+		if !assert.NoError(t, err) {
+			return
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy/b/c/", nil))
+		assert.Equal(t, "GET /base/b/c/? ", w.Body.String(), "paths should be cleaned")
+	})
+}
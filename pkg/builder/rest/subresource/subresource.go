@@ -0,0 +1,65 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subresource
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	contextutil "sigs.k8s.io/apiserver-runtime/pkg/util/context"
+)
+
+// getParent fetches the parent object from the parent storage plumbed in the context.
+func getParent(ctx context.Context, name string) (runtime.Object, error) {
+	getter, ok := contextutil.GetParentStorageGetter(ctx)
+	if !ok {
+		return nil, fmt.Errorf("parent storage not found in the context of subresource request for %q", name)
+	}
+	return getter.Get(ctx, name, &metav1.GetOptions{})
+}
+
+func parseBool(values *url.Values, key string) (bool, error) {
+	if !values.Has(key) {
+		return false, nil
+	}
+	v := values.Get(key)
+	if v == "" {
+		return true, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, apierrors.NewBadRequest(fmt.Sprintf("invalid value for %s: %v", key, err))
+	}
+	return b, nil
+}
+
+func parseInt64(values *url.Values, key string) (*int64, error) {
+	if !values.Has(key) {
+		return nil, nil
+	}
+	v, err := strconv.ParseInt(values.Get(key), 10, 64)
+	if err != nil {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid value for %s: %v", key, err))
+	}
+	return &v, nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subresource
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	contextutil "sigs.k8s.io/apiserver-runtime/pkg/util/context"
+)
+
+// withParent returns a context plumbed with a parent storage serving a single object.
+func withParent(ctx context.Context, obj *testResource) context.Context {
+	return contextutil.WithParentStorage(ctx, &testParentStorage{obj: obj})
+}
+
+type testParentStorage struct {
+	obj *testResource
+}
+
+func (t *testParentStorage) New() runtime.Object {
+	return &testResource{}
+}
+
+func (t *testParentStorage) Destroy() {}
+
+func (t *testParentStorage) Get(_ context.Context, _ string, _ *metav1.GetOptions) (runtime.Object, error) {
+	return t.obj, nil
+}
+
+type testResource struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Backend string `json:"backend,omitempty"`
+}
+
+func (t *testResource) DeepCopyObject() runtime.Object {
+	out := *t
+	t.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	return &out
+}
+
+func (t *testResource) GetObjectMeta() *metav1.ObjectMeta {
+	return &t.ObjectMeta
+}
+
+func (t *testResource) NamespaceScoped() bool {
+	return true
+}
+
+func (t *testResource) New() runtime.Object {
+	return &testResource{}
+}
+
+func (t *testResource) NewList() runtime.Object {
+	return &metav1.List{}
+}
+
+func (t *testResource) GetGroupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: "test.k8s.io", Version: "v1", Resource: "testresources"}
+}
+
+func (t *testResource) IsStorageVersion() bool {
+	return true
+}
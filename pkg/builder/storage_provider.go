@@ -10,15 +10,18 @@ import (
 	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/apiserver/pkg/registry/generic"
 	"k8s.io/apiserver/pkg/registry/generic/registry"
 	registryrest "k8s.io/apiserver/pkg/registry/rest"
 	"k8s.io/klog/v2"
 
 	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource"
+	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource/resourcestrategy"
 	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource/util"
 	"sigs.k8s.io/apiserver-runtime/pkg/builder/rest"
 	contextutil "sigs.k8s.io/apiserver-runtime/pkg/util/context"
@@ -61,7 +64,7 @@ func (s *subResourceStorageProvider) Get(scheme *runtime.Scheme, optsGetter gene
 	}
 
 	// status subresource
-	if strings.HasSuffix(s.subResourceGVR.Resource, "/status") {
+	if s.subResourceStorageProvider == nil && strings.HasSuffix(s.subResourceGVR.Resource, "/status") {
 		stdParentStorage, ok := parentStorage.(registryrest.StandardStorage)
 		if !ok {
 			return nil, fmt.Errorf("parent storageProvider for %v/%v/%v must implement rest.StandardStorage",
@@ -70,7 +73,7 @@ func (s *subResourceStorageProvider) Get(scheme *runtime.Scheme, optsGetter gene
 		return createStatusSubResourceStorage(stdParentStorage)
 	}
 	// scale subresource
-	if strings.HasSuffix(s.subResourceGVR.Resource, "/scale") {
+	if s.subResourceStorageProvider == nil && strings.HasSuffix(s.subResourceGVR.Resource, "/scale") {
 		getter, ok := parentStorage.(registryrest.Getter)
 		if !ok {
 			return nil, fmt.Errorf("parent storageProvider for %v/%v/%v must implement rest.Getter",
@@ -88,7 +91,7 @@ func (s *subResourceStorageProvider) Get(scheme *runtime.Scheme, optsGetter gene
 		}, nil
 	}
 	// connector
-	connectorSubResource, isConnector := subResourceStorage.(resource.ConnectorSubResource)
+	connectorSubResource, isConnector := subResourceStorage.(registryrest.Connecter)
 	if isConnector {
 		getter, ok := parentStorage.(registryrest.Getter)
 		if !ok {
@@ -102,24 +105,57 @@ func (s *subResourceStorageProvider) Get(scheme *runtime.Scheme, optsGetter gene
 			subResourceConnector:   connectorSubResource,
 		}, nil
 	}
-	// getter & updater
-	getterUpdaterSubResource, isGetterUpdater := subResourceStorage.(resource.GetterUpdaterSubResource)
-	if isGetterUpdater {
+	// getter, updater & creater, which may be combined
+	getterSubResource, isGetter := subResourceStorage.(registryrest.Getter)
+	updaterSubResource, isUpdater := subResourceStorage.(registryrest.Updater)
+	var creater *createrSubResourceStorage
+	if createrSubResource, isCreater := subResourceStorage.(registryrest.NamedCreater); isCreater {
+		gvk, err := requestKindFor(scheme, s.subResourceGVR.GroupVersion(), subResourceStorage)
+		if err != nil {
+			return nil, err
+		}
+		creater = &createrSubResourceStorage{
+			parentStorage:          parentStorage,
+			requestKind:            gvk,
+			subResourceConstructor: subResourceStorage,
+			subResourceCreater:     createrSubResource,
+		}
+	}
+	if isGetter && isUpdater {
 		stdParentStorage, ok := parentStorage.(registryrest.StandardStorage)
-		if ok {
-			return &commonSubResourceStorage{
-				parentStorage:          stdParentStorage,
-				subResourceConstructor: subResourceStorage,
-				subResourceGetter:      getterUpdaterSubResource,
-				subResourceUpdater:     getterUpdaterSubResource,
-			}, nil
+		if !ok {
+			klog.Infof("Parent storageProvider for %v/%v/%v must implement rest.StandardStorage",
+				s.subResourceGVR.Group, s.subResourceGVR.Version, s.subResourceGVR.Resource)
+			return subResourceStorage, nil
 		}
-		klog.Infof("Parent storageProvider for %v/%v/%v must implement rest.StandardStorage",
-			s.subResourceGVR.Group, s.subResourceGVR.Version, s.subResourceGVR.Resource)
+		common := &commonSubResourceStorage{
+			parentStorage:          stdParentStorage,
+			subResourceConstructor: subResourceStorage,
+			subResourceGetter:      getterSubResource,
+			subResourceUpdater:     updaterSubResource,
+		}
+		if creater != nil {
+			return &createrCommonSubResourceStorage{commonSubResourceStorage: common, createrSubResourceStorage: creater}, nil
+		}
+		return common, nil
+	}
+	if isGetter {
+		getter := &getterSubResourceStorage{
+			parentStorage:          parentStorage,
+			subResourceConstructor: subResourceStorage,
+			subResourceGetter:      getterSubResource,
+		}
+		if creater != nil {
+			return &createrGetterSubResourceStorage{getterSubResourceStorage: getter, createrSubResourceStorage: creater}, nil
+		}
+		return getter, nil
+	}
+	if creater != nil {
+		return creater, nil
 	}
 
 	// use the subresource storage directly
-	return s.subResourceStorageProvider(scheme, optsGetter)
+	return subResourceStorage, nil
 }
 
 func createStatusSubResourceStorage(parentStorage registryrest.StandardStorage) (registryrest.Storage, error) {
@@ -171,16 +207,39 @@ type statusSubResourceStrategy struct {
 	registryrest.RESTUpdateStrategy
 }
 
-// PrepareForUpdate calls the PrepareForUpdate function on obj if supported, otherwise does nothing.
+// PrepareForUpdate copies the status from obj onto old, discarding any other changes in obj, and then calls
+// the PrepareForStatusUpdate function on obj if supported.
 func (s *statusSubResourceStrategy) PrepareForUpdate(ctx context.Context, obj, old runtime.Object) {
 	// should panic/fail-fast upon casting failure
 	statusObj := obj.(resource.ObjectWithStatusSubResource)
-	statusOld := old.(resource.ObjectWithStatusSubResource)
+	// work on a copy so that old stays intact for the validation and warnings that follow
+	merged := old.DeepCopyObject().(resource.ObjectWithStatusSubResource)
 	// only modifies status
-	statusObj.GetStatus().CopyTo(statusOld)
-	if err := util.DeepCopy(statusOld, statusObj); err != nil {
+	statusObj.GetStatus().CopyTo(merged)
+	if err := util.DeepCopy(merged, statusObj); err != nil {
 		utilruntime.HandleError(err)
 	}
+	if v, ok := obj.(resourcestrategy.StatusPrepareForUpdater); ok {
+		v.PrepareForStatusUpdate(ctx, old)
+	}
+}
+
+// ValidateUpdate calls the ValidateStatusUpdate function on obj if supported, otherwise falls back to the
+// parent strategy.
+func (s *statusSubResourceStrategy) ValidateUpdate(ctx context.Context, obj, old runtime.Object) field.ErrorList {
+	if v, ok := obj.(resourcestrategy.StatusValidateUpdater); ok {
+		return v.ValidateStatusUpdate(ctx, old)
+	}
+	return s.RESTUpdateStrategy.ValidateUpdate(ctx, obj, old)
+}
+
+// WarningsOnUpdate calls the StatusWarningsOnUpdate function on obj if supported, otherwise falls back to the
+// parent strategy.
+func (s *statusSubResourceStrategy) WarningsOnUpdate(ctx context.Context, obj, old runtime.Object) []string {
+	if v, ok := obj.(resourcestrategy.StatusWarningsOnUpdater); ok {
+		return v.StatusWarningsOnUpdate(ctx, old)
+	}
+	return s.RESTUpdateStrategy.WarningsOnUpdate(ctx, obj, old)
 }
 
 // common subresource storage
@@ -226,6 +285,30 @@ func (c *commonSubResourceStorage) Update(ctx context.Context,
 		options)
 }
 
+// getter subresource storage
+type getterSubResourceStorage struct {
+	parentStorage          registryrest.Storage
+	subResourceConstructor registryrest.Storage
+	subResourceGetter      registryrest.Getter
+}
+
+var _ registryrest.Getter = &getterSubResourceStorage{}
+
+func (g *getterSubResourceStorage) New() runtime.Object {
+	return g.subResourceConstructor.New()
+}
+
+func (g *getterSubResourceStorage) Destroy() {
+	g.subResourceConstructor.Destroy()
+}
+
+func (g *getterSubResourceStorage) Get(ctx context.Context, name string, options *v1.GetOptions) (runtime.Object, error) {
+	return g.subResourceGetter.Get(
+		contextutil.WithParentStorage(ctx, g.parentStorage),
+		name,
+		options)
+}
+
 // connector subresource storage
 type connectorSubResourceStorage struct {
 	parentStorage          registryrest.Storage
@@ -261,6 +344,95 @@ func (c *connectorSubResourceStorage) ConnectMethods() []string {
 	return c.subResourceConnector.ConnectMethods()
 }
 
+// creater subresource storage
+type createrSubResourceStorage struct {
+	parentStorage          registryrest.Storage
+	requestKind            schema.GroupVersionKind
+	subResourceConstructor registryrest.Storage
+	subResourceCreater     registryrest.NamedCreater
+}
+
+var _ registryrest.NamedCreater = &createrSubResourceStorage{}
+var _ registryrest.GroupVersionKindProvider = &createrSubResourceStorage{}
+
+func (c *createrSubResourceStorage) New() runtime.Object {
+	return c.subResourceConstructor.New()
+}
+
+func (c *createrSubResourceStorage) Destroy() {
+	c.subResourceConstructor.Destroy()
+}
+
+func (c *createrSubResourceStorage) GroupVersionKind(containingGV schema.GroupVersion) schema.GroupVersionKind {
+	return c.requestKind
+}
+
+func (c *createrSubResourceStorage) Create(ctx context.Context,
+	name string,
+	obj runtime.Object,
+	createValidation registryrest.ValidateObjectFunc,
+	options *v1.CreateOptions) (runtime.Object, error) {
+	return c.subResourceCreater.Create(
+		contextutil.WithParentStorage(ctx, c.parentStorage),
+		name,
+		obj,
+		createValidation,
+		options)
+}
+
+// creater, getter & updater subresource storage
+type createrCommonSubResourceStorage struct {
+	*commonSubResourceStorage
+	*createrSubResourceStorage
+}
+
+var _ registryrest.Getter = &createrCommonSubResourceStorage{}
+var _ registryrest.Updater = &createrCommonSubResourceStorage{}
+var _ registryrest.NamedCreater = &createrCommonSubResourceStorage{}
+
+func (c *createrCommonSubResourceStorage) New() runtime.Object {
+	return c.commonSubResourceStorage.New()
+}
+
+func (c *createrCommonSubResourceStorage) Destroy() {
+	c.commonSubResourceStorage.Destroy()
+}
+
+// creater & getter subresource storage
+type createrGetterSubResourceStorage struct {
+	*getterSubResourceStorage
+	*createrSubResourceStorage
+}
+
+var _ registryrest.Getter = &createrGetterSubResourceStorage{}
+var _ registryrest.NamedCreater = &createrGetterSubResourceStorage{}
+
+func (c *createrGetterSubResourceStorage) New() runtime.Object {
+	return c.getterSubResourceStorage.New()
+}
+
+func (c *createrGetterSubResourceStorage) Destroy() {
+	c.getterSubResourceStorage.Destroy()
+}
+
+// requestKindFor returns the kind of the request object accepted by the subresource, preferring the kind
+// registered under the group version of the parent resource.
+func requestKindFor(scheme *runtime.Scheme, gv schema.GroupVersion, s registryrest.Storage) (schema.GroupVersionKind, error) {
+	if p, ok := s.(registryrest.GroupVersionKindProvider); ok {
+		return p.GroupVersionKind(gv), nil
+	}
+	kinds, _, err := scheme.ObjectKinds(s.New())
+	if err != nil {
+		return schema.GroupVersionKind{}, fmt.Errorf("request kind of subresource must be registered: %v", err)
+	}
+	for _, kind := range kinds {
+		if kind.GroupVersion() == gv {
+			return kind, nil
+		}
+	}
+	return kinds[0], nil
+}
+
 // scale subresource storage
 type scaleSubResourceStorage struct {
 	parentStorage        registryrest.Storage
@@ -290,11 +462,7 @@ func (s *scaleSubResourceStorage) Get(ctx context.Context, name string, options
 	if err != nil {
 		return nil, err
 	}
-	scaleParentObj, ok := parentObj.(resource.ObjectWithScaleSubResource)
-	if !ok {
-		return nil, fmt.Errorf("not a valid parent object, does it implement resource.ObjectWithScaleSubResource interface?")
-	}
-	return scaleParentObj.GetScale(), nil
+	return getScale(parentObj)
 }
 
 func (s *scaleSubResourceStorage) Update(ctx context.Context,
@@ -307,7 +475,7 @@ func (s *scaleSubResourceStorage) Update(ctx context.Context,
 	updatedObj, updated, err := s.parentStorageUpdater.Update(
 		contextutil.WithParentStorage(ctx, s.parentStorage),
 		name,
-		&scaleUpdatedObjectInfo{reqObjInfo: objInfo},
+		&scaleUpdatedObjectInfo{name: name, reqObjInfo: objInfo},
 		toScaleCreateValidation(createValidation),
 		toScaleUpdateValidation(updateValidation),
 		forceAllowCreate,
@@ -315,12 +483,17 @@ func (s *scaleSubResourceStorage) Update(ctx context.Context,
 	if err != nil {
 		return nil, false, err
 	}
-	return updatedObj.(resource.ObjectWithScaleSubResource).GetScale(), updated, nil
+	scale, err := getScale(updatedObj)
+	if err != nil {
+		return nil, false, err
+	}
+	return scale, updated, nil
 }
 
 var _ registryrest.UpdatedObjectInfo = &scaleUpdatedObjectInfo{}
 
 type scaleUpdatedObjectInfo struct {
+	name       string
 	reqObjInfo registryrest.UpdatedObjectInfo
 }
 
@@ -329,8 +502,10 @@ func (s *scaleUpdatedObjectInfo) Preconditions() *v1.Preconditions {
 }
 
 func (s *scaleUpdatedObjectInfo) UpdatedObject(ctx context.Context, oldObj runtime.Object) (newObj runtime.Object, err error) {
-	oldObjWithScale := oldObj.(resource.ObjectWithScaleSubResource)
-	oldScale := oldObjWithScale.GetScale()
+	oldScale, err := getScale(oldObj)
+	if err != nil {
+		return nil, err
+	}
 	obj, err := s.reqObjInfo.UpdatedObject(ctx, oldScale)
 	if err != nil {
 		return nil, err
@@ -342,28 +517,166 @@ func (s *scaleUpdatedObjectInfo) UpdatedObject(ctx context.Context, oldObj runti
 	if !ok {
 		return nil, errors.NewBadRequest(fmt.Sprintf("wrong object passed to Scale update: %v", obj))
 	}
-	oldObjWithScale.SetScale(scale)
+	if scale.Spec.Replicas < 0 {
+		return nil, errors.NewInvalid(autoscalingv1.SchemeGroupVersion.WithKind("Scale").GroupKind(), s.name, field.ErrorList{
+			field.Invalid(field.NewPath("spec", "replicas"), scale.Spec.Replicas, "must be greater than or equal to 0"),
+		})
+	}
+	newObj, err = setScale(oldObj, scale)
+	if err != nil {
+		return nil, err
+	}
 	if len(scale.ResourceVersion) != 0 {
 		// The client provided a resourceVersion precondition.
 		// Set that precondition and return any conflict errors to the client.
-		oldObjWithScale.GetObjectMeta().ResourceVersion = scale.ResourceVersion
+		newObj.(resource.Object).GetObjectMeta().ResourceVersion = scale.ResourceVersion
 	}
-	return oldObjWithScale, nil
+	return newObj, nil
 }
 
 func toScaleCreateValidation(f registryrest.ValidateObjectFunc) registryrest.ValidateObjectFunc {
 	return func(ctx context.Context, obj runtime.Object) error {
-		oldObjWithScale := obj.(resource.ObjectWithScaleSubResource)
-		return f(ctx, oldObjWithScale.GetScale())
+		scale, err := getScale(obj)
+		if err != nil {
+			return err
+		}
+		return f(ctx, scale)
 	}
 }
 
 func toScaleUpdateValidation(f registryrest.ValidateObjectUpdateFunc) registryrest.ValidateObjectUpdateFunc {
+	if f == nil {
+		return nil
+	}
 	return func(ctx context.Context, obj, old runtime.Object) error {
-		oldObjWithScale := old.(resource.ObjectWithScaleSubResource)
-		objWithScale := obj.(resource.ObjectWithScaleSubResource)
-		return f(ctx, objWithScale, oldObjWithScale)
+		scale, err := getScale(obj)
+		if err != nil {
+			return err
+		}
+		oldScale, err := getScale(old)
+		if err != nil {
+			return err
+		}
+		return f(ctx, scale, oldScale)
+	}
+}
+
+// getScale returns the Scale of a parent object implementing either resource.ObjectWithScaleSubResource or
+// resource.ObjectWithScaleSubResourcePaths.
+func getScale(obj runtime.Object) (*autoscalingv1.Scale, error) {
+	switch o := obj.(type) {
+	case resource.ObjectWithScaleSubResource:
+		return o.GetScale(), nil
+	case resource.ObjectWithScaleSubResourcePaths:
+		return scaleFromPaths(o)
+	}
+	return nil, fmt.Errorf("not a valid parent object, does it implement resource.ObjectWithScaleSubResource interface?")
+}
+
+// setScale applies the desired replicas of the Scale to the parent object, returning the updated parent.
+func setScale(obj runtime.Object, scale *autoscalingv1.Scale) (runtime.Object, error) {
+	switch o := obj.(type) {
+	case resource.ObjectWithScaleSubResource:
+		o.SetScale(scale)
+		return o, nil
+	case resource.ObjectWithScaleSubResourcePaths:
+		return scaleToPaths(o, scale)
+	}
+	return nil, fmt.Errorf("not a valid parent object, does it implement resource.ObjectWithScaleSubResource interface?")
+}
+
+func scaleFromPaths(obj resource.ObjectWithScaleSubResourcePaths) (*autoscalingv1.Scale, error) {
+	paths := obj.GetScaleSubResourcePaths()
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	specReplicas, _, err := unstructured.NestedInt64(content, splitScalePath(paths.SpecReplicasPath)...)
+	if err != nil {
+		return nil, err
+	}
+	statusReplicas, _, err := unstructured.NestedInt64(content, splitScalePath(paths.StatusReplicasPath)...)
+	if err != nil {
+		return nil, err
+	}
+	var selector string
+	if len(paths.LabelSelectorPath) > 0 {
+		selector, _, err = unstructured.NestedString(content, splitScalePath(paths.LabelSelectorPath)...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	om := obj.GetObjectMeta()
+	return &autoscalingv1.Scale{
+		ObjectMeta: v1.ObjectMeta{
+			Name:              om.Name,
+			Namespace:         om.Namespace,
+			UID:               om.UID,
+			ResourceVersion:   om.ResourceVersion,
+			CreationTimestamp: om.CreationTimestamp,
+		},
+		Spec: autoscalingv1.ScaleSpec{
+			Replicas: int32(specReplicas), // nolint:gosec
+		},
+		Status: autoscalingv1.ScaleStatus{
+			Replicas: int32(statusReplicas), // nolint:gosec
+			Selector: selector,
+		},
+	}, nil
+}
+
+func scaleToPaths(obj resource.ObjectWithScaleSubResourcePaths, scale *autoscalingv1.Scale) (runtime.Object, error) {
+	paths := obj.GetScaleSubResourcePaths()
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	if err := unstructured.SetNestedField(content, int64(scale.Spec.Replicas), splitScalePath(paths.SpecReplicasPath)...); err != nil {
+		return nil, err
+	}
+	newObj := obj.New()
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, newObj); err != nil {
+		return nil, err
+	}
+	return newObj, nil
+}
+
+// splitScalePath splits a JSON path such as ".spec.replicas" into its fields.
+func splitScalePath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "."), ".")
+}
+
+// validateScaleSubResourcePaths checks that the paths are JSON paths of fields under ".spec" or ".status", as
+// documented by resource.ScaleSubResourcePaths.
+func validateScaleSubResourcePaths(paths resource.ScaleSubResourcePaths) error {
+	var list field.ErrorList
+	validate := func(name, path string, roots ...string) {
+		fields := splitScalePath(path)
+		if !strings.HasPrefix(path, ".") || len(fields) < 2 {
+			list = append(list, field.Invalid(field.NewPath(name), path,
+				fmt.Sprintf("must be a JSON path under .%s", strings.Join(roots, " or ."))))
+			return
+		}
+		for _, f := range fields {
+			if f == "" {
+				list = append(list, field.Invalid(field.NewPath(name), path, "must not have empty fields"))
+				return
+			}
+		}
+		for _, root := range roots {
+			if fields[0] == root {
+				return
+			}
+		}
+		list = append(list, field.Invalid(field.NewPath(name), path,
+			fmt.Sprintf("must be a JSON path under .%s", strings.Join(roots, " or ."))))
+	}
+	validate("specReplicasPath", paths.SpecReplicasPath, "spec")
+	validate("statusReplicasPath", paths.StatusReplicasPath, "status")
+	if len(paths.LabelSelectorPath) > 0 {
+		validate("labelSelectorPath", paths.LabelSelectorPath, "status", "spec")
 	}
+	return list.ToAggregate()
 }
 
 type errs struct {
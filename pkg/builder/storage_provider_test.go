@@ -0,0 +1,432 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/registry/generic"
+	registryrest "k8s.io/apiserver/pkg/registry/rest"
+
+	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource"
+	builderrest "sigs.k8s.io/apiserver-runtime/pkg/builder/rest"
+	contextutil "sigs.k8s.io/apiserver-runtime/pkg/util/context"
+)
+
+func TestStatusSubResourceStrategy(t *testing.T) {
+	newStrategy := func() *statusSubResourceStrategy {
+		return &statusSubResourceStrategy{
+			RESTUpdateStrategy: builderrest.DefaultStrategy{Object: &testStatusResource{}},
+		}
+	}
+	t.Run("status update should only change status", func(t *testing.T) {
+		old := &testStatusResource{Spec: "old", Status: testStatus{Phase: "Pending"}}
+		obj := &testStatusResource{Spec: "new", Status: testStatus{Phase: "Running"}}
+		newStrategy().PrepareForUpdate(context.TODO(), obj, old)
+		assert.Equal(t, "old", obj.Spec)
+		assert.Equal(t, "Running", obj.Status.Phase)
+		assert.Equal(t, "Pending", old.Status.Phase, "old object should not be modified")
+		assert.Equal(t, "Pending", obj.preparedFrom, "PrepareForStatusUpdate should receive the old object")
+	})
+	t.Run("status update should use the status validation", func(t *testing.T) {
+		old := &testStatusResource{Spec: "old", Status: testStatus{Phase: "Running"}}
+		obj := &testStatusResource{Spec: "old", Status: testStatus{Phase: "Pending"}}
+		errs := newStrategy().ValidateUpdate(context.TODO(), obj, old)
+		assert.Len(t, errs, 1)
+		assert.Equal(t, "status.phase", errs[0].Field)
+	})
+	t.Run("status update should use the status warnings", func(t *testing.T) {
+		old := &testStatusResource{Status: testStatus{Phase: "Pending"}}
+		obj := &testStatusResource{Status: testStatus{Phase: "Unknown"}}
+		assert.Equal(t, []string{"phase Unknown is deprecated"}, newStrategy().WarningsOnUpdate(context.TODO(), obj, old))
+	})
+}
+
+func TestScaleSubResourcePaths(t *testing.T) {
+	obj := &testScaleResource{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", ResourceVersion: "7"},
+		Spec:       testScaleSpec{Replicas: 3, Image: "nginx"},
+		Status:     testScaleStatus{Replicas: 2, Selector: "app=foo"},
+	}
+	t.Run("get scale should read the declared paths", func(t *testing.T) {
+		scale, err := getScale(obj)
+		assert.NoError(t, err)
+		assert.Equal(t, "foo", scale.Name)
+		assert.Equal(t, "7", scale.ResourceVersion)
+		assert.Equal(t, int32(3), scale.Spec.Replicas)
+		assert.Equal(t, int32(2), scale.Status.Replicas)
+		assert.Equal(t, "app=foo", scale.Status.Selector)
+	})
+	t.Run("set scale should only change the spec replicas", func(t *testing.T) {
+		updated, err := setScale(obj, &autoscalingv1.Scale{Spec: autoscalingv1.ScaleSpec{Replicas: 5}})
+		assert.NoError(t, err)
+		assert.Equal(t, int32(5), updated.(*testScaleResource).Spec.Replicas)
+		assert.Equal(t, "nginx", updated.(*testScaleResource).Spec.Image)
+		assert.Equal(t, int32(2), updated.(*testScaleResource).Status.Replicas)
+		assert.Equal(t, int32(3), obj.Spec.Replicas, "the original object should not be modified")
+	})
+	t.Run("negative replicas should be rejected", func(t *testing.T) {
+		info := &scaleUpdatedObjectInfo{
+			name: "foo",
+			reqObjInfo: registryrest.DefaultUpdatedObjectInfo(&autoscalingv1.Scale{
+				Spec: autoscalingv1.ScaleSpec{Replicas: -1},
+			}),
+		}
+		_, err := info.UpdatedObject(context.TODO(), obj)
+		assert.True(t, errors.IsInvalid(err))
+	})
+}
+
+func TestScaleSubResourceUpdateValidation(t *testing.T) {
+	old := &testScaleResource{Spec: testScaleSpec{Replicas: 1}}
+	obj := &testScaleResource{Spec: testScaleSpec{Replicas: 2}}
+	var validated []runtime.Object
+	validation := toScaleUpdateValidation(func(_ context.Context, obj, old runtime.Object) error {
+		validated = []runtime.Object{obj, old}
+		return nil
+	})
+	assert.NoError(t, validation(context.TODO(), obj, old))
+	if assert.Len(t, validated, 2) {
+		assert.Equal(t, int32(2), validated[0].(*autoscalingv1.Scale).Spec.Replicas, "the new Scale should be validated")
+		assert.Equal(t, int32(1), validated[1].(*autoscalingv1.Scale).Spec.Replicas)
+	}
+	assert.Nil(t, toScaleUpdateValidation(nil))
+}
+
+func TestValidateScaleSubResourcePaths(t *testing.T) {
+	valid := resource.ScaleSubResourcePaths{
+		SpecReplicasPath:   ".spec.replicas",
+		StatusReplicasPath: ".status.replicas",
+	}
+	assert.NoError(t, validateScaleSubResourcePaths(valid))
+	withSelector := valid
+	withSelector.LabelSelectorPath = ".spec.selector"
+	assert.NoError(t, validateScaleSubResourcePaths(withSelector))
+
+	for name, paths := range map[string]resource.ScaleSubResourcePaths{
+		"empty spec path":          {StatusReplicasPath: ".status.replicas"},
+		"empty status path":        {SpecReplicasPath: ".spec.replicas"},
+		"spec path under status":   {SpecReplicasPath: ".status.replicas", StatusReplicasPath: ".status.replicas"},
+		"status path under spec":   {SpecReplicasPath: ".spec.replicas", StatusReplicasPath: ".spec.replicas"},
+		"path without leading dot": {SpecReplicasPath: "spec.replicas", StatusReplicasPath: ".status.replicas"},
+		"path of the root field":   {SpecReplicasPath: ".spec", StatusReplicasPath: ".status.replicas"},
+		"path with empty fields":   {SpecReplicasPath: ".spec..replicas", StatusReplicasPath: ".status.replicas"},
+		"selector path under metadata": {
+			SpecReplicasPath: ".spec.replicas", StatusReplicasPath: ".status.replicas", LabelSelectorPath: ".metadata.labels",
+		},
+	} {
+		assert.Error(t, validateScaleSubResourcePaths(paths), name)
+	}
+}
+
+func TestCreaterSubResource(t *testing.T) {
+	parentGVR := schema.GroupVersionResource{Group: "test.k8s.io", Version: "v1", Resource: "testparents"}
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(parentGVR.GroupVersion(), &testEviction{})
+	parent := &testParentStorage{}
+	sub := &testEvictionSubResource{}
+	provider := &subResourceStorageProvider{
+		subResourceGVR: parentGVR.GroupVersion().WithResource("testparents/eviction"),
+		parentStorageProvider: func(*runtime.Scheme, generic.RESTOptionsGetter) (registryrest.Storage, error) {
+			return parent, nil
+		},
+		subResourceStorageProvider: func(*runtime.Scheme, generic.RESTOptionsGetter) (registryrest.Storage, error) {
+			return sub, nil
+		},
+	}
+	storage, err := provider.Get(scheme, nil)
+	assert.NoError(t, err)
+
+	creater, ok := storage.(registryrest.NamedCreater)
+	if !assert.True(t, ok, "storage should expose POST") {
+		return
+	}
+	kindProvider := storage.(registryrest.GroupVersionKindProvider)
+	assert.Equal(t, parentGVR.GroupVersion().WithKind("testEviction"), kindProvider.GroupVersionKind(parentGVR.GroupVersion()))
+
+	_, err = creater.Create(context.TODO(), "foo", &testEviction{}, nil, &metav1.CreateOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", sub.evicted)
+	assert.Same(t, parent, sub.parent, "parent storage should be plumbed in the context")
+}
+
+func TestCreaterSubResourceWithOtherVerbs(t *testing.T) {
+	parentGVR := schema.GroupVersionResource{Group: "test.k8s.io", Version: "v1", Resource: "testparents"}
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(parentGVR.GroupVersion(), &testEviction{})
+	newStorage := func(parent, sub registryrest.Storage) registryrest.Storage {
+		provider := &subResourceStorageProvider{
+			subResourceGVR: parentGVR.GroupVersion().WithResource("testparents/eviction"),
+			parentStorageProvider: func(*runtime.Scheme, generic.RESTOptionsGetter) (registryrest.Storage, error) {
+				return parent, nil
+			},
+			subResourceStorageProvider: func(*runtime.Scheme, generic.RESTOptionsGetter) (registryrest.Storage, error) {
+				return sub, nil
+			},
+		}
+		storage, err := provider.Get(scheme, nil)
+		assert.NoError(t, err)
+		return storage
+	}
+
+	t.Run("getter, updater and creater should expose GET, PUT and POST", func(t *testing.T) {
+		sub := &testGetterUpdaterEvictionSubResource{}
+		storage := newStorage(&testStandardParentStorage{}, sub)
+		_, isGetter := storage.(registryrest.Getter)
+		_, isUpdater := storage.(registryrest.Updater)
+		creater, isCreater := storage.(registryrest.NamedCreater)
+		assert.True(t, isGetter)
+		assert.True(t, isUpdater)
+		if assert.True(t, isCreater) {
+			_, err := creater.Create(context.TODO(), "foo", &testEviction{}, nil, &metav1.CreateOptions{})
+			assert.NoError(t, err)
+			assert.Equal(t, "foo", sub.evicted)
+		}
+		assert.IsType(t, &testEviction{}, storage.New())
+	})
+	t.Run("getter and creater should expose GET and POST", func(t *testing.T) {
+		sub := &testGetterEvictionSubResource{}
+		storage := newStorage(&testParentStorage{}, sub)
+		getter, isGetter := storage.(registryrest.Getter)
+		_, isUpdater := storage.(registryrest.Updater)
+		_, isCreater := storage.(registryrest.NamedCreater)
+		assert.False(t, isUpdater)
+		assert.True(t, isCreater)
+		if assert.True(t, isGetter) {
+			obj, err := getter.Get(context.TODO(), "foo", &metav1.GetOptions{})
+			assert.NoError(t, err)
+			assert.Equal(t, "foo", obj.(*testEviction).Name)
+		}
+	})
+}
+
+var _ resource.CreaterSubResource = &testEvictionSubResource{}
+
+type testEvictionSubResource struct {
+	evicted string
+	parent  registryrest.Getter
+}
+
+func (t *testEvictionSubResource) SubResourceName() string {
+	return "eviction"
+}
+
+func (t *testEvictionSubResource) New() runtime.Object {
+	return &testEviction{}
+}
+
+func (t *testEvictionSubResource) Destroy() {}
+
+func (t *testEvictionSubResource) Create(ctx context.Context, name string, _ runtime.Object,
+	_ registryrest.ValidateObjectFunc, _ *metav1.CreateOptions) (runtime.Object, error) {
+	t.evicted = name
+	t.parent, _ = contextutil.GetParentStorageGetter(ctx)
+	return &metav1.Status{Status: metav1.StatusSuccess}, nil
+}
+
+type testGetterEvictionSubResource struct {
+	testEvictionSubResource
+}
+
+func (t *testGetterEvictionSubResource) Get(_ context.Context, name string, _ *metav1.GetOptions) (runtime.Object, error) {
+	return &testEviction{ObjectMeta: metav1.ObjectMeta{Name: name}}, nil
+}
+
+type testGetterUpdaterEvictionSubResource struct {
+	testGetterEvictionSubResource
+}
+
+func (t *testGetterUpdaterEvictionSubResource) Update(ctx context.Context, name string,
+	objInfo registryrest.UpdatedObjectInfo, _ registryrest.ValidateObjectFunc, _ registryrest.ValidateObjectUpdateFunc,
+	_ bool, _ *metav1.UpdateOptions) (runtime.Object, bool, error) {
+	obj, err := objInfo.UpdatedObject(ctx, &testEviction{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	return obj, false, err
+}
+
+// testStandardParentStorage is a parent storage implementing rest.StandardStorage, whose methods are not called.
+type testStandardParentStorage struct {
+	registryrest.StandardStorage
+}
+
+type testEviction struct {
+	metav1.TypeMeta
+	metav1.ObjectMeta
+}
+
+func (t *testEviction) DeepCopyObject() runtime.Object {
+	out := *t
+	return &out
+}
+
+type testParentStorage struct{}
+
+func (t *testParentStorage) New() runtime.Object {
+	return &testStatusResource{}
+}
+
+func (t *testParentStorage) Destroy() {}
+
+func (t *testParentStorage) Get(_ context.Context, name string, _ *metav1.GetOptions) (runtime.Object, error) {
+	return &testStatusResource{ObjectMeta: metav1.ObjectMeta{Name: name}}, nil
+}
+
+var _ resource.ObjectWithScaleSubResourcePaths = &testScaleResource{}
+
+type testScaleResource struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   testScaleSpec   `json:"spec,omitempty"`
+	Status testScaleStatus `json:"status,omitempty"`
+}
+
+type testScaleSpec struct {
+	Replicas int32  `json:"replicas"`
+	Image    string `json:"image,omitempty"`
+}
+
+type testScaleStatus struct {
+	Replicas int32  `json:"replicas"`
+	Selector string `json:"selector,omitempty"`
+}
+
+func (t *testScaleResource) GetScaleSubResourcePaths() resource.ScaleSubResourcePaths {
+	return resource.ScaleSubResourcePaths{
+		SpecReplicasPath:   ".spec.replicas",
+		StatusReplicasPath: ".status.replicas",
+		LabelSelectorPath:  ".status.selector",
+	}
+}
+
+func (t *testScaleResource) DeepCopyObject() runtime.Object {
+	out := *t
+	t.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	return &out
+}
+
+func (t *testScaleResource) GetObjectMeta() *metav1.ObjectMeta {
+	return &t.ObjectMeta
+}
+
+func (t *testScaleResource) NamespaceScoped() bool {
+	return true
+}
+
+func (t *testScaleResource) New() runtime.Object {
+	return &testScaleResource{}
+}
+
+func (t *testScaleResource) NewList() runtime.Object {
+	return &metav1.List{}
+}
+
+func (t *testScaleResource) GetGroupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: "test.k8s.io", Version: "v1", Resource: "testscaleresources"}
+}
+
+func (t *testScaleResource) IsStorageVersion() bool {
+	return true
+}
+
+var _ resource.ObjectWithStatusSubResource = &testStatusResource{}
+
+type testStatusResource struct {
+	metav1.TypeMeta
+	metav1.ObjectMeta
+
+	Spec   string
+	Status testStatus
+
+	preparedFrom string
+}
+
+type testStatus struct {
+	Phase string
+}
+
+func (t testStatus) SubResourceName() string {
+	return "status"
+}
+
+func (t testStatus) CopyTo(parent resource.ObjectWithStatusSubResource) {
+	parent.(*testStatusResource).Status = t
+}
+
+func (t *testStatusResource) GetStatus() resource.StatusSubResource {
+	return t.Status
+}
+
+func (t *testStatusResource) PrepareForStatusUpdate(_ context.Context, old runtime.Object) {
+	t.preparedFrom = old.(*testStatusResource).Status.Phase
+}
+
+func (t *testStatusResource) ValidateStatusUpdate(_ context.Context, old runtime.Object) field.ErrorList {
+	if old.(*testStatusResource).Status.Phase == "Running" && t.Status.Phase == "Pending" {
+		return field.ErrorList{field.Invalid(field.NewPath("status", "phase"), t.Status.Phase, "cannot go back to Pending")}
+	}
+	return nil
+}
+
+func (t *testStatusResource) StatusWarningsOnUpdate(_ context.Context, _ runtime.Object) []string {
+	if t.Status.Phase == "Unknown" {
+		return []string{"phase Unknown is deprecated"}
+	}
+	return nil
+}
+
+func (t *testStatusResource) DeepCopyInto(out *testStatusResource) {
+	*out = *t
+	t.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+}
+
+func (t *testStatusResource) DeepCopyObject() runtime.Object {
+	out := &testStatusResource{}
+	t.DeepCopyInto(out)
+	return out
+}
+
+func (t *testStatusResource) GetObjectMeta() *metav1.ObjectMeta {
+	return &t.ObjectMeta
+}
+
+func (t *testStatusResource) NamespaceScoped() bool {
+	return true
+}
+
+func (t *testStatusResource) New() runtime.Object {
+	return &testStatusResource{}
+}
+
+func (t *testStatusResource) NewList() runtime.Object {
+	return &metav1.List{}
+}
+
+func (t *testStatusResource) GetGroupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: "test.k8s.io", Version: "v1", Resource: "teststatusresources"}
+}
+
+func (t *testStatusResource) IsStorageVersion() bool {
+	return true
+}
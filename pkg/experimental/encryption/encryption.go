@@ -0,0 +1,95 @@
+// Package encryption encrypts resources at rest with the providers of the kube-apiserver EncryptionConfiguration --
+// aescbc, aesgcm, secretbox and KMS v2 -- for the storages of the builder which don't use the etcd options of the
+// server.
+//
+// Keys are rotated as in the kube-apiserver: the first provider of a resource encrypts the objects written, all
+// the providers decrypt the objects read.  Rotating a key means adding the new key to the providers, making it the
+// first once all the servers know it, and rewriting every object so that the old key can be removed.
+package encryption
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	apiserverv1 "k8s.io/apiserver/pkg/apis/apiserver/v1"
+	"k8s.io/apiserver/pkg/server/options/encryptionconfig"
+	"k8s.io/apiserver/pkg/storage/value"
+)
+
+// WriteConfiguration writes config to a new file readable only by the current user in dir, for the
+// "--encryption-provider-config" flag of the server, and returns its path.
+func WriteConfiguration(dir string, config *apiserverv1.EncryptionConfiguration) (string, error) {
+	config = config.DeepCopy()
+	config.APIVersion = apiserverv1.SchemeGroupVersion.String()
+	config.Kind = "EncryptionConfiguration"
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp(dir, "encryption-config-*.yaml")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// Option configures NewResourceTransformers.
+type Option func(*options)
+
+type options struct {
+	dir string
+}
+
+// WithConfigurationDir sets the private directory where NewResourceTransformers writes the configuration while it
+// loads it.  It defaults to a new directory in os.TempDir readable only by the current user.
+func WithConfigurationDir(dir string) Option {
+	return func(o *options) {
+		o.dir = dir
+	}
+}
+
+// NewResourceTransformers returns the transformers encrypting the resources of config, which leave the other
+// resources unencrypted.  The KMS plugins of config are called until ctx is done.
+//
+// The kube-apiserver only loads the configuration from a file: config, secrets included, is written to a file
+// readable only by the current user -- see WithConfigurationDir -- which is removed once loaded.
+func NewResourceTransformers(
+	ctx context.Context,
+	config *apiserverv1.EncryptionConfiguration,
+	opts ...Option,
+) (value.ResourceTransformers, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	dir := o.dir
+	if dir == "" {
+		var err error
+		if dir, err = os.MkdirTemp("", "encryption-"); err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+	}
+
+	path, err := WriteConfiguration(dir, config)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+	loaded, err := encryptionconfig.LoadEncryptionConfig(ctx, path, false, "")
+	if err != nil {
+		return nil, err
+	}
+	return encryptionconfig.StaticTransformers(loaded.Transformers), nil
+}
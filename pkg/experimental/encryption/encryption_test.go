@@ -0,0 +1,141 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	apiserverv1 "k8s.io/apiserver/pkg/apis/apiserver/v1"
+	"k8s.io/apiserver/pkg/storage/value"
+)
+
+var (
+	testResource = schema.GroupResource{Group: "example.com", Resource: "exampleresources"}
+	testDataCtx  = value.DefaultContext("example")
+)
+
+func aesgcmConfig(keys ...apiserverv1.Key) *apiserverv1.EncryptionConfiguration {
+	return &apiserverv1.EncryptionConfiguration{
+		Resources: []apiserverv1.ResourceConfiguration{{
+			Resources: []string{testResource.String()},
+			Providers: []apiserverv1.ProviderConfiguration{
+				{AESGCM: &apiserverv1.AESConfiguration{Keys: keys}},
+			},
+		}},
+	}
+}
+
+func TestResourceTransformersRotation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	key1 := apiserverv1.Key{Name: "key1", Secret: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))}
+	key2 := apiserverv1.Key{Name: "key2", Secret: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))}
+
+	old, err := NewResourceTransformers(ctx, aesgcmConfig(key1))
+	if !assert.NoError(t, err) {
+		return
+	}
+	stored, err := old.TransformerForResource(testResource).TransformToStorage(ctx, []byte("value"), testDataCtx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.False(t, bytes.Contains(stored, []byte("value")), "expected the value encrypted")
+	assert.True(t, bytes.HasPrefix(stored, []byte("k8s:enc:aesgcm:v1:key1:")))
+
+	plain, err := old.TransformerForResource(schema.GroupResource{Resource: "others"}).
+		TransformToStorage(ctx, []byte("value"), testDataCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, "value", string(plain), "expected the other resources unencrypted")
+
+	rotated, err := NewResourceTransformers(ctx, aesgcmConfig(key2, key1))
+	if !assert.NoError(t, err) {
+		return
+	}
+	out, stale, err := rotated.TransformerForResource(testResource).TransformFromStorage(ctx, stored, testDataCtx)
+	assert.NoError(t, err)
+	assert.True(t, stale, "expected the value encrypted with an old key")
+	assert.Equal(t, "value", string(out))
+
+	removed, err := NewResourceTransformers(ctx, aesgcmConfig(key2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, _, err = removed.TransformerForResource(testResource).TransformFromStorage(ctx, stored, testDataCtx)
+	assert.Error(t, err)
+}
+
+func TestResourceTransformersConfigurationDir(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	key := apiserverv1.Key{Name: "key1", Secret: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))}
+	dir := t.TempDir()
+
+	transformers, err := NewResourceTransformers(ctx, aesgcmConfig(key), WithConfigurationDir(dir))
+	if !assert.NoError(t, err) {
+		return
+	}
+	stored, err := transformers.TransformerForResource(testResource).TransformToStorage(ctx, []byte("value"), testDataCtx)
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(stored, []byte("k8s:enc:aesgcm:v1:key1:")))
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries, "expected the configuration removed once loaded")
+}
+
+func TestLocalKMS(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	kms, err := NewLocalKMS("key1", map[string][]byte{"key1": bytes.Repeat([]byte{1}, 32)})
+	if !assert.NoError(t, err) {
+		return
+	}
+	endpoint := "unix://" + filepath.Join(t.TempDir(), "kms.sock")
+	stop, err := ServeKMS(endpoint, kms)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer stop()
+
+	transformers, err := NewResourceTransformers(ctx, &apiserverv1.EncryptionConfiguration{
+		Resources: []apiserverv1.ResourceConfiguration{{
+			Resources: []string{testResource.String()},
+			Providers: []apiserverv1.ProviderConfiguration{{
+				KMS: &apiserverv1.KMSConfiguration{
+					APIVersion: "v2",
+					Name:       "local",
+					Endpoint:   endpoint,
+					Timeout:    &metav1.Duration{Duration: time.Second},
+				},
+			}},
+		}},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	transformer := transformers.TransformerForResource(testResource)
+
+	var stored []byte
+	// the plugin is probed in the background
+	err = wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, 30*time.Second, true,
+		func(ctx context.Context) (bool, error) {
+			var err error
+			stored, err = transformer.TransformToStorage(ctx, []byte("value"), testDataCtx)
+			return err == nil, nil
+		})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, bytes.HasPrefix(stored, []byte("k8s:enc:kms:v2:local:")))
+	out, _, err := transformer.TransformFromStorage(ctx, stored, testDataCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, "value", string(out))
+}
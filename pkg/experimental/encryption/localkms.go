@@ -0,0 +1,106 @@
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	kmsapi "k8s.io/kms/apis/v2"
+	"k8s.io/kms/pkg/service"
+	"k8s.io/kms/pkg/util"
+)
+
+// kmsConnectionTimeout is the timeout of the connections to the KMS plugins served.
+const kmsConnectionTimeout = 3 * time.Second
+
+var _ service.Service = &LocalKMS{}
+
+// LocalKMS is a KMS v2 plugin encrypting with AES-GCM keys held in memory.  It stands in for a real key management
+// service in tests and development, as the keys are no better protected than the ones of the aesgcm provider.
+type LocalKMS struct {
+	keyID string
+	aeads map[string]cipher.AEAD
+}
+
+// NewLocalKMS returns a plugin encrypting with keys[keyID] and decrypting with any of keys, which are indexed by key
+// ID and 16, 24 or 32 bytes long.  Rotating the key means adding a new key and making it the current one: the
+// server then encrypts with the new key, and still decrypts the objects written with the old one.
+func NewLocalKMS(keyID string, keys map[string][]byte) (*LocalKMS, error) {
+	if _, ok := keys[keyID]; !ok {
+		return nil, fmt.Errorf("no key %q", keyID)
+	}
+	k := &LocalKMS{keyID: keyID, aeads: map[string]cipher.AEAD{}}
+	for id, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		k.aeads[id] = aead
+	}
+	return k, nil
+}
+
+// Encrypt implements service.Service.
+func (k *LocalKMS) Encrypt(_ context.Context, _ string, data []byte) (*service.EncryptResponse, error) {
+	aead := k.aeads[k.keyID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return &service.EncryptResponse{
+		Ciphertext: aead.Seal(nonce, nonce, data, []byte(k.keyID)),
+		KeyID:      k.keyID,
+	}, nil
+}
+
+// Decrypt implements service.Service.
+func (k *LocalKMS) Decrypt(_ context.Context, _ string, req *service.DecryptRequest) ([]byte, error) {
+	aead, ok := k.aeads[req.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", req.KeyID)
+	}
+	if len(req.Ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := req.Ciphertext[:aead.NonceSize()], req.Ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(req.KeyID))
+}
+
+// Status implements service.Service.
+func (k *LocalKMS) Status(context.Context) (*service.StatusResponse, error) {
+	return &service.StatusResponse{Version: "v2", Healthz: "ok", KeyID: k.keyID}, nil
+}
+
+// ServeKMS serves a KMS v2 plugin on endpoint, a "unix://PATH" URL as in the KMS configuration of the server, until
+// stop is called.  A socket left at PATH by a previous process is replaced.
+func ServeKMS(endpoint string, kms service.Service) (stop func(), err error) {
+	addr, err := util.ParseEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(addr); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	l, err := net.Listen("unix", addr)
+	if err != nil {
+		return nil, err
+	}
+	server := grpc.NewServer(grpc.ConnectionTimeout(kmsConnectionTimeout))
+	kmsapi.RegisterKeyManagementServiceServer(server, service.NewGRPCService(addr, kmsConnectionTimeout, kms))
+	go func() {
+		_ = server.Serve(l)
+	}()
+	return server.Stop, nil
+}
@@ -0,0 +1,213 @@
+// Package migration rewrites the objects of resources in their current storage version, so that the objects written
+// before the storage version changed -- or before an encryption key was rotated -- no longer depend on the old
+// version or key.
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+)
+
+// defaultPageSize is the number of objects listed and rewritten at once.
+const defaultPageSize = 500
+
+// Migrator rewrites objects through the API, as the kube-storage-version-migrator does: each object is updated
+// without changes, which makes the storage encode it in the current storage version and encrypt it with the
+// current key.  Objects updated or deleted concurrently are skipped, as they no longer need to be rewritten.
+type Migrator struct {
+	client    dynamic.Interface
+	statePath string
+	pageSize  int64
+	trigger   string
+
+	// mu serializes the updates of the state file
+	mu sync.Mutex
+}
+
+// Option configures the Migrator returned by NewMigrator.
+type Option func(*Migrator)
+
+// WithTrigger runs the completed migrations again when trigger differs from the trigger of the migrations recorded,
+// e.g. a hash of the encryption configuration so that the objects are re-encrypted once a key is rotated.
+func WithTrigger(trigger string) Option {
+	return func(m *Migrator) {
+		m.trigger = trigger
+	}
+}
+
+// NewMigrator returns a Migrator rewriting objects with client, and recording its progress in the file at
+// statePath.  Migrations are not resumable if statePath is empty.
+func NewMigrator(client dynamic.Interface, statePath string, opts ...Option) *Migrator {
+	m := &Migrator{
+		client:    client,
+		statePath: statePath,
+		pageSize:  defaultPageSize,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// state is the content of the state file, the progress of the migration of each GroupResource.
+type state struct {
+	Resources map[string]*resourceState `json:"resources"`
+}
+
+type resourceState struct {
+	// Version is the storage version the objects are migrated to
+	Version string `json:"version"`
+	// Trigger is the trigger of the Migrator when the migration started
+	Trigger string `json:"trigger,omitempty"`
+	// Continue is the continue token of the next page to migrate
+	Continue string `json:"continue,omitempty"`
+	// Migrated is the number of objects migrated so far
+	Migrated int64 `json:"migrated"`
+	// Complete is true once every object has been migrated
+	Complete bool `json:"complete,omitempty"`
+}
+
+// Migrate rewrites the objects of gvr, the resource in its storage version.  The progress is logged and recorded in
+// the state file after every page: an interrupted migration resumes after the last page migrated, and a completed
+// migration isn't run again until the storage version or the trigger changes.  A migration whose continue token has
+// expired starts over.
+func (m *Migrator) Migrate(ctx context.Context, gvr schema.GroupVersionResource) error {
+	gr := gvr.GroupResource()
+	rs, err := m.resourceState(gr)
+	if err != nil {
+		return err
+	}
+	if rs.Version != gvr.Version || rs.Trigger != m.trigger {
+		rs = resourceState{Version: gvr.Version, Trigger: m.trigger}
+	}
+	if rs.Complete {
+		klog.V(2).Infof("Objects of %v already migrated to %s", gr, gvr.Version)
+		return nil
+	}
+	if rs.Continue != "" {
+		klog.Infof("Resuming the migration of %v to %s after %d objects", gr, gvr.Version, rs.Migrated)
+	}
+
+	for {
+		list, err := m.client.Resource(gvr).List(ctx, metav1.ListOptions{Limit: m.pageSize, Continue: rs.Continue})
+		if apierrors.IsResourceExpired(err) && rs.Continue != "" {
+			klog.Infof("Restarting the migration of %v to %s, its continue token has expired", gr, gvr.Version)
+			rs = resourceState{Version: gvr.Version, Trigger: m.trigger}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for i := range list.Items {
+			if err := m.migrateObject(ctx, gvr, &list.Items[i]); err != nil {
+				return err
+			}
+		}
+		rs.Migrated += int64(len(list.Items))
+		rs.Continue = list.GetContinue()
+		rs.Complete = rs.Continue == ""
+		if err := m.setResourceState(gr, rs); err != nil {
+			return err
+		}
+		if rs.Complete {
+			klog.Infof("Migrated the %d objects of %v to %s", rs.Migrated, gr, gvr.Version)
+			return nil
+		}
+		klog.Infof("Migrated %d objects of %v to %s so far", rs.Migrated, gr, gvr.Version)
+	}
+}
+
+// migrateObject updates obj without changes.
+func (m *Migrator) migrateObject(ctx context.Context, gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	_, err := m.client.Resource(gvr).Namespace(obj.GetNamespace()).Update(ctx, obj, metav1.UpdateOptions{})
+	if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+		// rewritten or deleted since listed
+		return nil
+	}
+	return err
+}
+
+func (m *Migrator) resourceState(gr schema.GroupResource) (resourceState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, err := m.readState()
+	if err != nil {
+		return resourceState{}, err
+	}
+	if rs, ok := s.Resources[gr.String()]; ok {
+		return *rs, nil
+	}
+	return resourceState{}, nil
+}
+
+func (m *Migrator) setResourceState(gr schema.GroupResource, rs resourceState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.statePath == "" {
+		return nil
+	}
+	s, err := m.readState()
+	if err != nil {
+		return err
+	}
+	s.Resources[gr.String()] = &rs
+	return m.writeState(s)
+}
+
+func (m *Migrator) readState() (*state, error) {
+	s := &state{Resources: map[string]*resourceState{}}
+	if m.statePath == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(m.statePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Resources == nil {
+		s.Resources = map[string]*resourceState{}
+	}
+	return s, nil
+}
+
+// writeState replaces the state file with a temporary file, so that it is never left partially written.
+func (m *Migrator) writeState(s *state) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(m.statePath)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(m.statePath)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), m.statePath)
+}
@@ -0,0 +1,134 @@
+package migration
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+var testGVR = schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "exampleresources"}
+
+func testObject(name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("example.com/v1")
+	obj.SetKind("ExampleResource")
+	obj.SetNamespace("default")
+	obj.SetName(name)
+	return obj
+}
+
+// pagedResource lists "a" and "b" in a first page, and "c" in a second page, as the fake client doesn't paginate.
+type pagedResource struct {
+	dynamic.NamespaceableResourceInterface
+}
+
+func (r pagedResource) List(_ context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetAPIVersion("example.com/v1")
+	list.SetKind("ExampleResourceList")
+	switch opts.Continue {
+	case "":
+		list.Items = []unstructured.Unstructured{*testObject("a"), *testObject("b")}
+		list.SetContinue("page2")
+	case "page2":
+		list.Items = []unstructured.Unstructured{*testObject("c")}
+	default:
+		return nil, apierrors.NewResourceExpired("expired")
+	}
+	return list, nil
+}
+
+type pagedClient struct {
+	*fake.FakeDynamicClient
+}
+
+func (c pagedClient) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return pagedResource{c.FakeDynamicClient.Resource(gvr)}
+}
+
+// newTestClient returns a client listing the objects in two pages, which records the names of the objects updated.
+func newTestClient(updated *[]string) dynamic.Interface {
+	objs := []runtime.Object{testObject("a"), testObject("b"), testObject("c")}
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{testGVR: "ExampleResourceList"}, objs...)
+	client.PrependReactor("update", testGVR.Resource, func(action clienttesting.Action) (bool, runtime.Object, error) {
+		*updated = append(*updated, action.(clienttesting.UpdateAction).GetObject().(*unstructured.Unstructured).GetName())
+		return false, nil, nil
+	})
+	return pagedClient{client}
+}
+
+func TestMigrate(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "migration.json")
+	var updated []string
+	m := NewMigrator(newTestClient(&updated), statePath)
+
+	assert.NoError(t, m.Migrate(context.TODO(), testGVR))
+	assert.Equal(t, []string{"a", "b", "c"}, updated)
+	rs, err := m.resourceState(testGVR.GroupResource())
+	assert.NoError(t, err)
+	assert.Equal(t, resourceState{Version: "v1", Migrated: 3, Complete: true}, rs)
+
+	t.Run("completed migrations should not run again", func(t *testing.T) {
+		updated = nil
+		assert.NoError(t, m.Migrate(context.TODO(), testGVR))
+		assert.Empty(t, updated)
+	})
+	t.Run("migrations should run again for a new storage version", func(t *testing.T) {
+		updated = nil
+		assert.NoError(t, m.Migrate(context.TODO(), testGVR.GroupResource().WithVersion("v2")))
+		assert.Len(t, updated, 3)
+	})
+	t.Run("migrations should run again for a new trigger", func(t *testing.T) {
+		v2 := testGVR.GroupResource().WithVersion("v2")
+		updated = nil
+		m := NewMigrator(newTestClient(&updated), statePath, WithTrigger("rotated"))
+		assert.NoError(t, m.Migrate(context.TODO(), v2))
+		assert.Len(t, updated, 3)
+		rs, err := m.resourceState(testGVR.GroupResource())
+		assert.NoError(t, err)
+		assert.Equal(t, resourceState{Version: "v2", Trigger: "rotated", Migrated: 3, Complete: true}, rs)
+
+		updated = nil
+		assert.NoError(t, m.Migrate(context.TODO(), v2))
+		assert.Empty(t, updated)
+	})
+}
+
+func TestMigrateResume(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "migration.json")
+	var updated []string
+	m := NewMigrator(newTestClient(&updated), statePath)
+
+	assert.NoError(t, m.setResourceState(testGVR.GroupResource(),
+		resourceState{Version: "v1", Continue: "page2", Migrated: 2}))
+	assert.NoError(t, m.Migrate(context.TODO(), testGVR))
+	assert.Equal(t, []string{"c"}, updated, "expected the migration resumed after the first page")
+
+	t.Run("expired continue tokens should restart the migration", func(t *testing.T) {
+		updated = nil
+		assert.NoError(t, m.setResourceState(testGVR.GroupResource(),
+			resourceState{Version: "v1", Continue: "expired", Migrated: 2}))
+		assert.NoError(t, m.Migrate(context.TODO(), testGVR))
+		assert.Equal(t, []string{"a", "b", "c"}, updated)
+		rs, err := m.resourceState(testGVR.GroupResource())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), rs.Migrated)
+	})
+	t.Run("the state file should not be left partially written", func(t *testing.T) {
+		entries, err := os.ReadDir(filepath.Dir(statePath))
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+}
@@ -0,0 +1,170 @@
+package backend
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+	genericoptions "k8s.io/apiserver/pkg/server/options"
+
+	"sigs.k8s.io/apiserver-runtime/pkg/builder"
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/storage/internal/storageutil/storagetest"
+)
+
+func parseFlags(t *testing.T, args ...string) *Options {
+	o := NewOptions()
+	fs := o.AddFlags(pflag.NewFlagSet("test", pflag.ContinueOnError))
+	assert.NoError(t, fs.Parse(args))
+	return o
+}
+
+func TestOptionsBackends(t *testing.T) {
+	testObjects := schema.GroupResource{Group: "test.k8s.io", Resource: "testobjects"}
+	others := schema.GroupResource{Group: "test.k8s.io", Resource: "others"}
+	tests := []struct {
+		name     string
+		args     []string
+		expected map[schema.GroupResource]backend
+		err      string
+	}{
+		{
+			name: "default",
+			expected: map[schema.GroupResource]backend{
+				testObjects: {kind: Etcd},
+				others:      {kind: Etcd},
+			},
+		},
+		{
+			name: "backend",
+			args: []string{"--storage-backend=sqlite:data/state.db"},
+			expected: map[schema.GroupResource]backend{
+				testObjects: {kind: SQLite, location: "data/state.db"},
+				others:      {kind: SQLite, location: "data/state.db"},
+			},
+		},
+		{
+			name: "overrides",
+			args: []string{
+				"--storage-backend=filepath:data",
+				"--storage-backend-override=testobjects.test.k8s.io=memory",
+				"--storage-backend-override=others.test.k8s.io=kine:mysql://u:p@tcp(localhost:3306)/db",
+			},
+			expected: map[schema.GroupResource]backend{
+				testObjects: {kind: Memory},
+				others:      {kind: Kine, location: "mysql://u:p@tcp(localhost:3306)/db"},
+			},
+		},
+		{
+			name: "unknown backend",
+			args: []string{"--storage-backend=redis"},
+			err:  `--storage-backend: unknown storage backend "redis"`,
+		},
+		{
+			name: "missing location",
+			args: []string{"--storage-backend=filepath"},
+			err:  `--storage-backend: storage backend "filepath" requires a location`,
+		},
+		{
+			name: "unexpected location",
+			args: []string{"--storage-backend-override=testobjects.test.k8s.io=memory:data"},
+			err:  `--storage-backend-override: testobjects.test.k8s.io: storage backend "memory" doesn't take a location`,
+		},
+		{
+			name: "invalid override",
+			args: []string{"--storage-backend-override=memory"},
+			err:  `--storage-backend-override: expected RESOURCE.GROUP=BACKEND, got "memory"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := parseFlags(t, tt.args...)
+			for gr, expected := range tt.expected {
+				b, err := o.backendFor(gr)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, b, gr.String())
+			}
+			if tt.err != "" {
+				_, err := o.backendFor(testObjects)
+				assert.ErrorContains(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestOptionsApplyTo(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		expectedEtcd bool
+		err          string
+	}{
+		{
+			name:         "etcd",
+			expectedEtcd: true,
+		},
+		{
+			name:         "override to etcd",
+			args:         []string{"--storage-backend=memory", "--storage-backend-override=testobjects.test.k8s.io=etcd"},
+			expectedEtcd: true,
+		},
+		{
+			name: "without etcd",
+			args: []string{"--storage-backend=memory"},
+		},
+		{
+			name: "invalid backend",
+			args: []string{"--storage-backend=redis"},
+			err:  `--storage-backend: unknown storage backend "redis", expected one of etcd, memory, filepath, sqlite or kine`,
+		},
+		{
+			name: "unknown resource",
+			args: []string{"--storage-backend-override=foo.example.com=memory"},
+			err:  `--storage-backend-override: unknown resource foo.example.com, expected one of [testobjects.test.k8s.io]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := parseFlags(t, tt.args...)
+			o.NewStorageProvider(&storagetest.Object{})
+			options := &builder.ServerOptions{
+				RecommendedOptions: &genericoptions.RecommendedOptions{Etcd: &genericoptions.EtcdOptions{}},
+			}
+			err := o.ApplyTo(options)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedEtcd, options.RecommendedOptions.Etcd != nil)
+		})
+	}
+}
+
+func TestOptionsNewStorageProvider(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(storagetest.GroupVersion, &storagetest.Object{}, &storagetest.ObjectList{})
+	metav1.AddToGroupVersion(scheme, storagetest.GroupVersion)
+	root := t.TempDir()
+
+	o := parseFlags(t, "--storage-backend=filepath:"+root, "--storage-backend-override=others.test.k8s.io=memory")
+	s, err := o.NewStorageProvider(&storagetest.Object{})(scheme, nil)
+	if assert.NoError(t, err) {
+		defer s.Destroy()
+		ctx := genericapirequest.WithNamespace(context.TODO(), "default")
+		_, err := s.(rest.Creater).Create(ctx, &storagetest.Object{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}, nil,
+			&metav1.CreateOptions{})
+		assert.NoError(t, err)
+		assert.FileExists(t, filepath.Join(root, "test.k8s.io", "testobjects", "default", "foo.json"))
+	}
+
+	o = parseFlags(t, "--storage-backend=redis")
+	_, err = o.NewStorageProvider(&storagetest.Object{})(scheme, nil)
+	assert.Error(t, err)
+}
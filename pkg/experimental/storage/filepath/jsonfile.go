@@ -2,6 +2,8 @@
 package filepath
 
 import (
+	"fmt"
+
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/apiserver/pkg/registry/generic"
@@ -37,11 +39,35 @@ import (
 //	  WithResourceAndHandler(&v1alpha1.ExampleResource{},
 //	        jsonfile.NewJsonFileStorageProvider(&v1alpha1.ExampleResource{}, /*the root file-path*/ "data")).
 //	  Build()
-func NewJSONFilepathStorageProvider(obj resource.Object, rootPath string) builderrest.ResourceHandlerProvider {
+//
+// Options such as WithFilesystemNotifications are passed to NewFilepathREST.
+func NewJSONFilepathStorageProvider(obj resource.Object, rootPath string, opts ...Option) builderrest.ResourceHandlerProvider {
+	return NewFilepathStorageProvider(obj, rootPath, runtime.ContentTypeJSON, opts...)
+}
+
+// NewFilepathStorageProvider is like NewJSONFilepathStorageProvider, storing objects in the format of mediaType:
+//
+//   - runtime.ContentTypeJSON in ".json" files
+//   - runtime.ContentTypeYAML in ".yaml" files, which are easier to edit by hand
+//   - runtime.ContentTypeProtobuf in ".pb" files, for types generated with protobuf support
+//
+// Files in the other formats are still read, so that a directory can be migrated from one format to another: each
+// object is rewritten in the new format when it is next updated.  If an object is stored in several formats, the
+// file in the format of mediaType takes precedence.
+func NewFilepathStorageProvider(
+	obj resource.Object,
+	rootPath string,
+	mediaType string,
+	opts ...Option,
+) builderrest.ResourceHandlerProvider {
 	return func(scheme *runtime.Scheme, getter generic.RESTOptionsGetter) (rest.Storage, error) {
+		ext, ok := fileExtensions[mediaType]
+		if !ok {
+			return nil, fmt.Errorf("unsupported media type %q for the filepath storage", mediaType)
+		}
 		gr := obj.GetGroupVersionResource().GroupResource()
 		codec, _, err := storage.NewStorageCodec(storage.StorageCodecConfig{
-			StorageMediaType:  runtime.ContentTypeJSON,
+			StorageMediaType:  mediaType,
 			StorageSerializer: serializer.NewCodecFactory(scheme),
 			StorageVersion:    scheme.PrioritizedVersionsForGroup(obj.GetGroupVersionResource().Group)[0],
 			MemoryVersion:     scheme.PrioritizedVersionsForGroup(obj.GetGroupVersionResource().Group)[0],
@@ -58,6 +84,14 @@ func NewJSONFilepathStorageProvider(obj resource.Object, rootPath string) builde
 			obj.NamespaceScoped(),
 			obj.New,
 			obj.NewList,
+			append([]Option{WithFileExtension(ext)}, opts...)...,
 		), nil
 	}
 }
+
+// fileExtensions are the extensions of the files written for each supported media type.
+var fileExtensions = map[string]string{
+	runtime.ContentTypeJSON:     ".json",
+	runtime.ContentTypeYAML:     ".yaml",
+	runtime.ContentTypeProtobuf: ".pb",
+}
@@ -5,12 +5,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
 
+	"github.com/fsnotify/fsnotify"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -20,12 +24,25 @@ import (
 	"k8s.io/apimachinery/pkg/watch"
 	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/storage/value"
+	"k8s.io/apiserver/pkg/util/dryrun"
+	"k8s.io/apiserver/pkg/warning"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/storage/internal/broadcaster"
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/storage/internal/storageutil"
 )
 
 // ErrFileNotExists means the file doesn't actually exist.
+//
+// Deprecated: the storage returns status errors, use apierrors.IsNotFound.  Its not found errors still match
+// ErrFileNotExists with errors.Is.
 var ErrFileNotExists = fmt.Errorf("file doesn't exist")
 
 // ErrNamespaceNotExists means the directory for the namespace doesn't actually exist.
+//
+// Deprecated: the storage returns status errors, use apierrors.IsBadRequest.  Its errors for requests without a
+// namespace still match ErrNamespaceNotExists with errors.Is.
 var ErrNamespaceNotExists = errors.New("namespace does not exist")
 
 var _ rest.StandardStorage = &filepathREST{}
@@ -40,51 +57,92 @@ func NewFilepathREST(
 	isNamespaced bool,
 	newFunc func() runtime.Object,
 	newListFunc func() runtime.Object,
+	opts ...Option,
 ) rest.Storage {
 	objRoot := filepath.Join(rootpath, groupResource.Group, groupResource.Resource)
 	if err := ensureDir(objRoot); err != nil {
 		panic(fmt.Sprintf("unable to write data dir: %s", err))
 	}
+	if err := removeTempFiles(objRoot); err != nil {
+		panic(fmt.Sprintf("unable to clean data dir: %s", err))
+	}
 
 	// file REST
 	rest := &filepathREST{
 		TableConvertor: rest.NewDefaultTableConvertor(groupResource),
+		groupResource:  groupResource,
 		codec:          codec,
 		objRootPath:    objRoot,
 		isNamespaced:   isNamespaced,
 		newFunc:        newFunc,
 		newListFunc:    newListFunc,
-		watchers:       make(map[int]*jsonWatch, 10),
+		extension:      ".json",
+	}
+	for _, opt := range opts {
+		opt(rest)
+	}
+	if !slices.Contains(objectFileExtensions, rest.extension) {
+		panic(fmt.Sprintf("unsupported file extension %q, expected one of %v", rest.extension, objectFileExtensions))
+	}
+	if rest.transformer != nil {
+		rest.codec = &transformingCodec{
+			Codec:       codec,
+			transformer: rest.transformer,
+			dataCtx:     value.DefaultContext(groupResource.String()),
+		}
+	}
+	if rest.notify {
+		rest.known = map[string]knownFile{}
+	}
+	rest.initResourceVersion()
+	rest.broadcaster = broadcaster.New(rest.resourceVersion, newFunc)
+	if rest.notify {
+		if err := rest.startNotifications(); err != nil {
+			panic(fmt.Sprintf("unable to watch data dir: %s", err))
+		}
 	}
 	return rest
 }
 
 type filepathREST struct {
 	rest.TableConvertor
-	codec        runtime.Codec
-	objRootPath  string
-	isNamespaced bool
-
-	muWatchers sync.RWMutex
-	watchers   map[int]*jsonWatch
+	groupResource schema.GroupResource
+	codec         runtime.Codec
+	objRootPath   string
+	isNamespaced  bool
+	// extension is the extension of the files written, matching the format of the codec
+	extension string
+	// transformer encrypts the files, if not nil
+	transformer value.Transformer
+
+	// locks serializes the read-modify-write sequences on each file
+	locks keyLocks
+
+	// muCommit serializes writes, resourceVersion is the resource version of the last write
+	muCommit        sync.Mutex
+	resourceVersion uint64
+	broadcaster     *broadcaster.Broadcaster
+
+	// notify enables turning changes made by other processes into watch events, known holds the last state of
+	// each file for this purpose
+	notify  bool
+	known   map[string]knownFile
+	fsWatch *fsnotify.Watcher
 
 	newFunc     func() runtime.Object
 	newListFunc func() runtime.Object
 }
 
-func (f *filepathREST) notifyWatchers(ev watch.Event) {
-	f.muWatchers.RLock()
-	for _, w := range f.watchers {
-		w.ch <- ev
-	}
-	f.muWatchers.RUnlock()
-}
-
 func (f *filepathREST) New() runtime.Object {
 	return f.newFunc()
 }
 
-func (f *filepathREST) Destroy() {}
+func (f *filepathREST) Destroy() {
+	if f.fsWatch != nil {
+		_ = f.fsWatch.Close()
+	}
+	f.broadcaster.Shutdown()
+}
 
 func (f *filepathREST) NewList() runtime.Object {
 	return f.newListFunc()
@@ -99,24 +157,60 @@ func (f *filepathREST) Get(
 	name string,
 	options *metav1.GetOptions,
 ) (runtime.Object, error) {
-	return read(f.codec, f.objectFileName(ctx, name), f.newFunc)
+	filename, err := f.objectFileName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := f.readObject(f.objectFile(filename))
+	if err != nil {
+		return nil, f.storageError(err, name)
+	}
+	return obj, nil
 }
 
 func (f *filepathREST) List(
 	ctx context.Context,
 	options *metainternalversion.ListOptions,
 ) (runtime.Object, error) {
-	newListObj := f.NewList()
-	v, err := getListPrt(newListObj)
+	startKey, err := storageutil.StartKey(options)
 	if err != nil {
 		return nil, err
 	}
 
+	// the resource version is read before walking so that a watch started from it doesn't miss any change
+	rv := f.currentResourceVersion()
+	p := storageutil.Predicate(options)
+	var items []storageutil.Item
 	dirname := f.objectDirName(ctx)
-	if err := visitDir(dirname, f.newFunc, f.codec, func(path string, obj runtime.Object) {
-		appendItem(v, obj)
-	}); err != nil {
-		return nil, fmt.Errorf("failed walking filepath %v", dirname)
+	if exists(dirname) {
+		if err := visitDir(dirname, f.readObject, func(path string, obj runtime.Object) error {
+			if f.objectFile(path) != path {
+				// also stored in another format, which takes precedence
+				return nil
+			}
+			if ok, err := p.Matches(obj); err != nil || !ok {
+				return err
+			}
+			key, err := storageutil.ObjectKey(obj)
+			if err != nil {
+				return err
+			}
+			items = append(items, storageutil.Item{Key: key, Object: obj})
+			return nil
+		}, func(path string, err error) {
+			reportInvalidFile(ctx, path, err)
+		}); err != nil {
+			return nil, apierrors.NewInternalError(fmt.Errorf("failed walking filepath %v: %w", dirname, err))
+		}
+	}
+
+	newListObj := f.NewList()
+	limit := int64(0)
+	if options != nil {
+		limit = options.Limit
+	}
+	if err := storageutil.SetList(newListObj, items, startKey, limit, rv); err != nil {
+		return nil, err
 	}
 	return newListObj, nil
 }
@@ -127,42 +221,43 @@ func (f *filepathREST) Create(
 	createValidation rest.ValidateObjectFunc,
 	options *metav1.CreateOptions,
 ) (runtime.Object, error) {
+	accessor, err := storageutil.BeforeCreate(ctx, obj, f.isNamespaced)
+	if err != nil {
+		return nil, err
+	}
 	if createValidation != nil {
 		if err := createValidation(ctx, obj); err != nil {
 			return nil, err
 		}
 	}
 
-	if f.isNamespaced {
-		// ensures namespace dir
-		ns, ok := genericapirequest.NamespaceFrom(ctx)
-		if !ok {
-			return nil, ErrNamespaceNotExists
-		}
-		if err := ensureDir(filepath.Join(f.objRootPath, ns)); err != nil {
-			return nil, err
-		}
-	}
-
-	accessor, err := meta.Accessor(obj)
+	filename, err := f.objectFileName(ctx, accessor.GetName())
 	if err != nil {
 		return nil, err
 	}
-	filename := f.objectFileName(ctx, accessor.GetName())
+	unlock := f.locks.lock(filename)
+	defer unlock()
 
-	if exists(filename) {
-		return nil, ErrFileNotExists
+	if exists(f.objectFile(filename)) {
+		if accessor.GetGenerateName() != "" {
+			return nil, apierrors.NewGenerateNameConflict(f.groupResource, accessor.GetName(), 1)
+		}
+		return nil, apierrors.NewAlreadyExists(f.groupResource, accessor.GetName())
 	}
-
-	if err := write(f.codec, filename, obj); err != nil {
-		return nil, err
+	if options != nil && dryrun.IsDryRun(options.DryRun) {
+		return obj, nil
 	}
 
-	f.notifyWatchers(watch.Event{
-		Type:   watch.Added,
-		Object: obj,
-	})
+	// ensures namespace dir
+	if err := ensureDir(filepath.Dir(filename)); err != nil {
+		return nil, f.storageError(err, accessor.GetName())
+	}
 
+	if err := f.commit(watch.Added, filename, obj, nil, func() error {
+		return write(f.codec, filename, obj)
+	}); err != nil {
+		return nil, f.storageError(err, accessor.GetName())
+	}
 	return obj, nil
 }
 
@@ -175,61 +270,78 @@ func (f *filepathREST) Update(
 	forceAllowCreate bool,
 	options *metav1.UpdateOptions,
 ) (runtime.Object, bool, error) {
+	filename, err := f.objectFileName(ctx, name)
+	if err != nil {
+		return nil, false, err
+	}
+	unlock := f.locks.lock(filename)
+	defer unlock()
+
 	isCreate := false
 	oldObj, err := f.Get(ctx, name, nil)
 	if err != nil {
-		if !forceAllowCreate {
+		if !apierrors.IsNotFound(err) || !forceAllowCreate {
 			return nil, false, err
 		}
 		isCreate = true
 	}
 
-	// TODO: should not be necessary, verify Get works before creating filepath
-	if f.isNamespaced {
-		// ensures namespace dir
-		ns, ok := genericapirequest.NamespaceFrom(ctx)
-		if !ok {
-			return nil, false, ErrNamespaceNotExists
-		}
-		if err := ensureDir(filepath.Join(f.objRootPath, ns)); err != nil {
-			return nil, false, err
-		}
-	}
-
 	updatedObj, err := objInfo.UpdatedObject(ctx, oldObj)
 	if err != nil {
 		return nil, false, err
 	}
-	filename := f.objectFileName(ctx, name)
 
+	dryRun := options != nil && dryrun.IsDryRun(options.DryRun)
 	if isCreate {
+		accessor, err := meta.Accessor(updatedObj)
+		if err != nil {
+			return nil, false, err
+		}
+		if err := storageutil.EnsureNamespace(ctx, accessor, f.isNamespaced); err != nil {
+			return nil, false, err
+		}
+		storageutil.InitObjectMeta(accessor)
 		if createValidation != nil {
 			if err := createValidation(ctx, updatedObj); err != nil {
 				return nil, false, err
 			}
 		}
-		if err := write(f.codec, filename, updatedObj); err != nil {
-			return nil, false, err
+		if dryRun {
+			accessor.SetResourceVersion("")
+			return updatedObj, true, nil
+		}
+		// ensures namespace dir
+		if err := ensureDir(filepath.Dir(filename)); err != nil {
+			return nil, false, f.storageError(err, name)
+		}
+		if err := f.commit(watch.Added, filename, updatedObj, nil, func() error {
+			return write(f.codec, filename, updatedObj)
+		}); err != nil {
+			return nil, false, f.storageError(err, name)
 		}
-		f.notifyWatchers(watch.Event{
-			Type:   watch.Added,
-			Object: updatedObj,
-		})
 		return updatedObj, true, nil
 	}
 
+	if err := storageutil.BeforeUpdate(ctx, f.groupResource, updatedObj, oldObj, f.isNamespaced); err != nil {
+		return nil, false, err
+	}
 	if updateValidation != nil {
 		if err := updateValidation(ctx, updatedObj, oldObj); err != nil {
 			return nil, false, err
 		}
 	}
-	if err := write(f.codec, filename, updatedObj); err != nil {
-		return nil, false, err
+	if dryRun {
+		// the object keeps the resource version it is stored with
+		if err := storageutil.CopyResourceVersion(updatedObj, oldObj); err != nil {
+			return nil, false, err
+		}
+		return updatedObj, false, nil
+	}
+	if err := f.commit(watch.Modified, filename, updatedObj, oldObj, func() error {
+		return f.replace(filename, updatedObj)
+	}); err != nil {
+		return nil, false, f.storageError(err, name)
 	}
-	f.notifyWatchers(watch.Event{
-		Type:   watch.Modified,
-		Object: updatedObj,
-	})
 	return updatedObj, false, nil
 }
 
@@ -238,29 +350,41 @@ func (f *filepathREST) Delete(
 	name string,
 	deleteValidation rest.ValidateObjectFunc,
 	options *metav1.DeleteOptions) (runtime.Object, bool, error) {
-	filename := f.objectFileName(ctx, name)
-	if !exists(filename) {
-		return nil, false, ErrFileNotExists
+	filename, err := f.objectFileName(ctx, name)
+	if err != nil {
+		return nil, false, err
 	}
+	unlock := f.locks.lock(filename)
+	defer unlock()
 
 	oldObj, err := f.Get(ctx, name, nil)
 	if err != nil {
 		return nil, false, err
 	}
+	if err := storageutil.CheckDeletePreconditions(f.groupResource, oldObj, options); err != nil {
+		return nil, false, err
+	}
 	if deleteValidation != nil {
 		if err := deleteValidation(ctx, oldObj); err != nil {
 			return nil, false, err
 		}
 	}
+	if options != nil && dryrun.IsDryRun(options.DryRun) {
+		return oldObj, true, nil
+	}
 
-	if err := os.Remove(filename); err != nil {
-		return nil, false, err
+	// the deleted object carries the resource version of the deletion
+	deletedObj := oldObj.DeepCopyObject()
+	filename = f.objectFile(filename)
+	if err := f.commit(watch.Deleted, filename, deletedObj, nil, func() error {
+		if err := os.Remove(filename); err != nil {
+			return err
+		}
+		return f.removeOtherFormats(filename)
+	}); err != nil {
+		return nil, false, f.storageError(err, name)
 	}
-	f.notifyWatchers(watch.Event{
-		Type:   watch.Deleted,
-		Object: oldObj,
-	})
-	return oldObj, true, nil
+	return deletedObj, true, nil
 }
 
 func (f *filepathREST) DeleteCollection(
@@ -269,54 +393,216 @@ func (f *filepathREST) DeleteCollection(
 	options *metav1.DeleteOptions,
 	listOptions *metainternalversion.ListOptions,
 ) (runtime.Object, error) {
+	list, err := f.List(ctx, listOptions)
+	if err != nil {
+		return nil, err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+
 	newListObj := f.NewList()
 	v, err := getListPrt(newListObj)
 	if err != nil {
 		return nil, err
 	}
-	dirname := f.objectDirName(ctx)
-	if err := visitDir(dirname, f.newFunc, f.codec, func(path string, obj runtime.Object) {
-		_ = os.Remove(path)
-		appendItem(v, obj)
-	}); err != nil {
-		return nil, fmt.Errorf("failed walking filepath %v", dirname)
+	for _, item := range items {
+		accessor, err := meta.Accessor(item)
+		if err != nil {
+			return nil, err
+		}
+		itemCtx := ctx
+		if accessor.GetNamespace() != "" {
+			itemCtx = genericapirequest.WithNamespace(ctx, accessor.GetNamespace())
+		}
+		deleted, _, err := f.Delete(itemCtx, accessor.GetName(), deleteValidation, options)
+		if apierrors.IsNotFound(err) {
+			// deleted concurrently
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		appendItem(v, deleted)
 	}
 	return newListObj, nil
 }
 
-func (f *filepathREST) objectFileName(ctx context.Context, name string) string {
+func (f *filepathREST) objectFileName(ctx context.Context, name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return "", apierrors.NewBadRequest(fmt.Sprintf("invalid name %q", name))
+	}
 	if f.isNamespaced {
-		// FIXME: return error if namespace is not found
 		ns, _ := genericapirequest.NamespaceFrom(ctx)
-		return filepath.Join(f.objRootPath, ns, name+".json")
+		if ns == "" {
+			return "", &deprecatedStatusError{
+				StatusError: apierrors.NewBadRequest("namespace is required"),
+				deprecated:  ErrNamespaceNotExists,
+			}
+		}
+		return filepath.Join(f.objRootPath, ns, name+f.extension), nil
+	}
+	return filepath.Join(f.objRootPath, name+f.extension), nil
+}
+
+// objectFile returns the file the object of filename is stored in: the file with the extension of the storage if it
+// exists, or else the first existing file with another extension, so that directories holding objects in several
+// formats can be read while migrating from one format to another.  The file with the extension of the storage is
+// returned if no file exists.
+func (f *filepathREST) objectFile(filename string) string {
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	primary := base + f.extension
+	if exists(primary) {
+		return primary
+	}
+	for _, ext := range objectFileExtensions {
+		if ext != f.extension && exists(base+ext) {
+			return base + ext
+		}
+	}
+	return primary
+}
+
+// replace writes obj to filename, in the format of the storage, and removes the files holding the object in other
+// formats.  It must be called while committing.
+func (f *filepathREST) replace(filename string, obj runtime.Object) error {
+	if err := write(f.codec, filename, obj); err != nil {
+		return err
+	}
+	return f.removeOtherFormats(filename)
+}
+
+// removeOtherFormats removes the files holding the object of filename in other formats than the one of filename, so
+// that they neither shadow nor resurrect the object.  It must be called while committing.
+func (f *filepathREST) removeOtherFormats(filename string) error {
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	for _, ext := range objectFileExtensions {
+		other := base + ext
+		if other == filename {
+			continue
+		}
+		if f.known != nil {
+			delete(f.known, other)
+		}
+		if err := os.Remove(other); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
 	}
-	return filepath.Join(f.objRootPath, name+".json")
+	return nil
 }
 
 func (f *filepathREST) objectDirName(ctx context.Context) string {
 	if f.isNamespaced {
-		// FIXME: return error if namespace is not found
+		// all namespaces are visited if the request has no namespace
 		ns, _ := genericapirequest.NamespaceFrom(ctx)
 		return filepath.Join(f.objRootPath, ns)
 	}
 	return f.objRootPath
 }
 
-func write(encoder runtime.Encoder, filepath string, obj runtime.Object) error {
+// storageError converts errors of the filesystem to status errors.
+func (f *filepathREST) storageError(err error, name string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, fs.ErrNotExist):
+		return &deprecatedStatusError{
+			StatusError: apierrors.NewNotFound(f.groupResource, name),
+			deprecated:  ErrFileNotExists,
+		}
+	case errors.Is(err, fs.ErrExist):
+		return apierrors.NewAlreadyExists(f.groupResource, name)
+	}
+	if _, ok := err.(apierrors.APIStatus); ok {
+		return err
+	}
+	return apierrors.NewInternalError(err)
+}
+
+// deprecatedStatusError is a status error which also matches the deprecated error returned in its place before.
+type deprecatedStatusError struct {
+	*apierrors.StatusError
+	deprecated error
+}
+
+func (e *deprecatedStatusError) Unwrap() []error {
+	return []error{e.StatusError, e.deprecated}
+}
+
+// write encodes obj to filename.
+func write(encoder runtime.Encoder, filename string, obj runtime.Object) error {
 	buf := new(bytes.Buffer)
 	if err := encoder.Encode(obj, buf); err != nil {
 		return err
 	}
-	return os.WriteFile(filepath, buf.Bytes(), 0600)
+	return writeFile(filename, buf.Bytes())
 }
 
-func read(decoder runtime.Decoder, path string, newFunc func() runtime.Object) (runtime.Object, error) {
-	content, err := os.ReadFile(filepath.Clean(path))
+// writeFile writes data to a temporary file renamed to filename once synced, so that a crash never leaves a
+// partial file behind.
+func writeFile(filename string, data []byte) error {
+	dir := filepath.Dir(filename)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(filename)+tempFileSuffix+"*")
 	if err != nil {
-		return nil, err
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
 	}
-	newObj := newFunc()
-	decodedObj, _, err := decoder.Decode(content, nil, newObj)
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// tempFileSuffix is part of the names of the temporary files created by writeFile.
+const tempFileSuffix = ".tmp-"
+
+// syncDir persists the entries of a directory, such as a rename.
+func syncDir(dirname string) error {
+	d, err := os.Open(dirname)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// removeTempFiles removes the temporary files left by writes interrupted by a crash.
+func removeTempFiles(dirname string) error {
+	return filepath.WalkDir(dirname, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasPrefix(d.Name(), ".") && strings.Contains(d.Name(), tempFileSuffix) {
+			klog.Infof("Removing temporary file %s", path)
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// errEmptyFile is returned for empty files, which decode without error.  Files are empty for a moment when they are
+// rewritten in place by other processes.
+var errEmptyFile = errors.New("empty file")
+
+func decode(decoder runtime.Decoder, content []byte, newFunc func() runtime.Object) (runtime.Object, error) {
+	if len(bytes.TrimSpace(content)) == 0 {
+		return nil, errEmptyFile
+	}
+	decodedObj, _, err := decoder.Decode(content, nil, newFunc())
 	if err != nil {
 		return nil, err
 	}
@@ -335,26 +621,55 @@ func ensureDir(dirname string) error {
 	return nil
 }
 
-func visitDir(dirname string, newFunc func() runtime.Object, codec runtime.Decoder, visitFunc func(string, runtime.Object)) error {
+// visitDir reads the objects stored under dirname with readFunc.  Files which can't be decoded are passed to
+// invalidFunc and skipped.
+func visitDir(
+	dirname string,
+	readFunc func(string) (runtime.Object, error),
+	visitFunc func(string, runtime.Object) error,
+	invalidFunc func(string, error),
+) error {
 	return filepath.Walk(dirname, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
+			if path != dirname && errors.Is(err, fs.ErrNotExist) {
+				// removed while walking
+				return nil
+			}
 			return err
 		}
 		if info.IsDir() {
 			return nil
 		}
-		if !strings.HasSuffix(info.Name(), ".json") {
+		if !isObjectFile(info.Name()) {
+			return nil
+		}
+		newObj, err := readFunc(path)
+		if errors.Is(err, fs.ErrNotExist) {
 			return nil
 		}
-		newObj, err := read(codec, path, newFunc)
 		if err != nil {
-			return err
+			invalidFunc(path, err)
+			return nil
 		}
-		visitFunc(path, newObj)
-		return nil
+		return visitFunc(path, newObj)
 	})
 }
 
+// objectFileExtensions are the extensions of the files objects are read from, in order of precedence when an object
+// is stored in several formats.
+var objectFileExtensions = []string{".json", ".yaml", ".yml", ".pb"}
+
+// isObjectFile returns true for the names of the files holding objects.
+func isObjectFile(name string) bool {
+	return slices.Contains(objectFileExtensions, filepath.Ext(name)) && !strings.HasPrefix(name, ".")
+}
+
+// reportInvalidFile logs a file which can't be decoded and returns a warning to the client.
+func reportInvalidFile(ctx context.Context, path string, err error) {
+	klog.Errorf("Ignoring invalid file %s: %v", path, err)
+	warning.AddWarning(ctx, "", fmt.Sprintf("ignoring invalid file %s: %v", filepath.Base(path), err))
+}
+
 func appendItem(v reflect.Value, obj runtime.Object) {
 	v.Set(reflect.Append(v, reflect.ValueOf(obj).Elem()))
 }
@@ -372,49 +687,11 @@ func getListPrt(listObj runtime.Object) (reflect.Value, error) {
 }
 
 func (f *filepathREST) Watch(ctx context.Context, options *metainternalversion.ListOptions) (watch.Interface, error) {
-	jw := &jsonWatch{
-		id: len(f.watchers),
-		f:  f,
-		ch: make(chan watch.Event, 10),
-	}
-	// On initial watch, send all the existing objects
-	list, err := f.List(ctx, options)
+	watchOptions, err := storageutil.WatchOptions(ctx, options, f.isNamespaced, f.currentResourceVersion(), f.List)
 	if err != nil {
 		return nil, err
 	}
-
-	danger := reflect.ValueOf(list).Elem()
-	items := danger.FieldByName("Items")
-
-	for i := 0; i < items.Len(); i++ {
-		obj := items.Index(i).Addr().Interface().(runtime.Object)
-		jw.ch <- watch.Event{
-			Type:   watch.Added,
-			Object: obj,
-		}
-	}
-
-	f.muWatchers.Lock()
-	f.watchers[jw.id] = jw
-	f.muWatchers.Unlock()
-
-	return jw, nil
-}
-
-type jsonWatch struct {
-	f  *filepathREST
-	id int
-	ch chan watch.Event
-}
-
-func (w *jsonWatch) Stop() {
-	w.f.muWatchers.Lock()
-	delete(w.f.watchers, w.id)
-	w.f.muWatchers.Unlock()
-}
-
-func (w *jsonWatch) ResultChan() <-chan watch.Event {
-	return w.ch
+	return f.broadcaster.Watch(watchOptions)
 }
 
 // TODO: implement custom table printer optionally
@@ -0,0 +1,671 @@
+package filepath
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	apiserverv1 "k8s.io/apiserver/pkg/apis/apiserver/v1"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+	serverstorage "k8s.io/apiserver/pkg/server/storage"
+	"k8s.io/apiserver/pkg/warning"
+
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/encryption"
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/storage/internal/storageutil/storagetest"
+)
+
+func newTestREST(t *testing.T, root string, opts ...Option) *filepathREST {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(storagetest.GroupVersion, &storagetest.Object{}, &storagetest.ObjectList{})
+	metav1.AddToGroupVersion(scheme, storagetest.GroupVersion)
+	codec := serializer.NewCodecFactory(scheme).LegacyCodec(storagetest.GroupVersion)
+	return newTestRESTWithCodec(t, root, codec, opts...)
+}
+
+func newTestRESTWithCodec(t *testing.T, root string, codec runtime.Codec, opts ...Option) *filepathREST {
+	return NewFilepathREST(
+		storagetest.GroupVersion.WithResource("testobjects").GroupResource(),
+		codec,
+		root,
+		true,
+		func() runtime.Object { return &storagetest.Object{} },
+		func() runtime.Object { return &storagetest.ObjectList{} },
+		opts...,
+	).(*filepathREST)
+}
+
+func testContext() context.Context {
+	return genericapirequest.WithNamespace(context.TODO(), "default")
+}
+
+func TestFilepathRESTObjectMeta(t *testing.T) {
+	root := t.TempDir()
+	f := newTestREST(t, root)
+	ctx := testContext()
+
+	created, err := f.Create(ctx, &storagetest.Object{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "foo-"},
+		Spec:       "a",
+	}, nil, &metav1.CreateOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	obj := created.(*storagetest.Object)
+	assert.Regexp(t, "^foo-.....$", obj.Name)
+	assert.NotEmpty(t, obj.UID)
+	assert.False(t, obj.CreationTimestamp.IsZero())
+	assert.Equal(t, int64(1), obj.Generation)
+	assert.Equal(t, "1", obj.ResourceVersion)
+
+	t.Run("status only updates should not increment the generation", func(t *testing.T) {
+		update := obj.DeepCopyObject().(*storagetest.Object)
+		update.Status = "ready"
+		updated, _, err := f.Update(ctx, obj.Name, rest.DefaultUpdatedObjectInfo(update), nil, nil, false, &metav1.UpdateOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), updated.(*storagetest.Object).Generation)
+		assert.Equal(t, "2", updated.(*storagetest.Object).ResourceVersion)
+		assert.Equal(t, obj.UID, updated.(*storagetest.Object).UID)
+	})
+	t.Run("spec updates should increment the generation", func(t *testing.T) {
+		update := &storagetest.Object{ObjectMeta: metav1.ObjectMeta{Name: obj.Name}, Spec: "b"}
+		updated, _, err := f.Update(ctx, obj.Name, rest.DefaultUpdatedObjectInfo(update), nil, nil, false, &metav1.UpdateOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), updated.(*storagetest.Object).Generation)
+		assert.Equal(t, obj.UID, updated.(*storagetest.Object).UID, "uid should be preserved")
+		assert.Equal(t, obj.CreationTimestamp.Unix(), updated.(*storagetest.Object).CreationTimestamp.Unix())
+	})
+	t.Run("stale updates should conflict", func(t *testing.T) {
+		_, _, err := f.Update(ctx, obj.Name, rest.DefaultUpdatedObjectInfo(obj), nil, nil, false, &metav1.UpdateOptions{})
+		assert.True(t, apierrors.IsConflict(err))
+	})
+	t.Run("stale deletes should conflict", func(t *testing.T) {
+		_, _, err := f.Delete(ctx, obj.Name, nil, &metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{ResourceVersion: &obj.ResourceVersion},
+		})
+		assert.True(t, apierrors.IsConflict(err))
+	})
+	t.Run("resource versions should resume after a restart", func(t *testing.T) {
+		assert.Equal(t, uint64(3), newTestREST(t, root).currentResourceVersion())
+	})
+	t.Run("resource versions of deleted objects should not be reused after a restart", func(t *testing.T) {
+		_, _, err := f.Delete(ctx, obj.Name, nil, &metav1.DeleteOptions{})
+		assert.NoError(t, err)
+		restarted := newTestREST(t, root)
+		assert.Equal(t, uint64(4), restarted.currentResourceVersion())
+		created, err := restarted.Create(ctx, &storagetest.Object{ObjectMeta: metav1.ObjectMeta{Name: "bar"}}, nil, &metav1.CreateOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "5", created.(*storagetest.Object).ResourceVersion)
+	})
+}
+
+func TestFilepathRESTErrors(t *testing.T) {
+	f := newTestREST(t, t.TempDir())
+	ctx := testContext()
+	gr := storagetest.GroupVersion.WithResource("testobjects").GroupResource()
+	statusOf := func(err error) metav1.Status {
+		status, ok := err.(apierrors.APIStatus)
+		if !assert.True(t, ok, "expected a status error, got %v", err) {
+			return metav1.Status{}
+		}
+		return status.Status()
+	}
+
+	_, err := f.Get(ctx, "foo", &metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+	assert.Equal(t, gr.Group, statusOf(err).Details.Group)
+	assert.Equal(t, gr.Resource, statusOf(err).Details.Kind)
+
+	_, _, err = f.Delete(ctx, "foo", nil, &metav1.DeleteOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+	assert.ErrorIs(t, err, ErrFileNotExists, "the deprecated error should still match")
+
+	_, _, err = f.Update(ctx, "foo", rest.DefaultUpdatedObjectInfo(&storagetest.Object{}), nil, nil, false, &metav1.UpdateOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	_, err = f.Create(ctx, &storagetest.Object{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}, nil, &metav1.CreateOptions{})
+	assert.NoError(t, err)
+	_, err = f.Create(ctx, &storagetest.Object{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}, nil, &metav1.CreateOptions{})
+	assert.True(t, apierrors.IsAlreadyExists(err))
+
+	_, err = f.Get(context.TODO(), "foo", &metav1.GetOptions{})
+	assert.True(t, apierrors.IsBadRequest(err), "missing namespace should be rejected")
+	assert.ErrorIs(t, err, ErrNamespaceNotExists, "the deprecated error should still match")
+	_, err = f.Get(ctx, "../foo", &metav1.GetOptions{})
+	assert.True(t, apierrors.IsBadRequest(err), "names with path separators should be rejected")
+
+	list, err := f.List(genericapirequest.WithNamespace(context.TODO(), "empty"), nil)
+	assert.NoError(t, err, "listing a namespace without objects should succeed")
+	assert.Empty(t, list.(*storagetest.ObjectList).Items)
+}
+
+func TestFilepathRESTSelectors(t *testing.T) {
+	f := newTestREST(t, t.TempDir())
+	ctx := testContext()
+	for name, app := range map[string]string{"a": "x", "b": "y"} {
+		_, err := f.Create(ctx, &storagetest.Object{ObjectMeta: metav1.ObjectMeta{
+			Name: name, Labels: map[string]string{"app": app},
+		}}, nil, &metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+	_, err := f.Create(genericapirequest.WithNamespace(context.TODO(), "other"), &storagetest.Object{ObjectMeta: metav1.ObjectMeta{
+		Name: "c", Labels: map[string]string{"app": "x"},
+	}}, nil, &metav1.CreateOptions{})
+	assert.NoError(t, err)
+	selectX := &metainternalversion.ListOptions{LabelSelector: labels.SelectorFromSet(labels.Set{"app": "x"})}
+	names := func(list runtime.Object) []string {
+		var names []string
+		for _, item := range list.(*storagetest.ObjectList).Items {
+			names = append(names, item.Namespace+"/"+item.Name)
+		}
+		return names
+	}
+
+	t.Run("list should only return matching objects", func(t *testing.T) {
+		list, err := f.List(ctx, selectX)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"default/a"}, names(list))
+
+		list, err = f.List(context.TODO(), &metainternalversion.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("metadata.name", "c"),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"other/c"}, names(list))
+	})
+	t.Run("watch should only send matching events", func(t *testing.T) {
+		w, err := f.Watch(ctx, selectX)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer w.Stop()
+		ev := <-w.ResultChan()
+		assert.Equal(t, watch.Added, ev.Type)
+		assert.Equal(t, "a", ev.Object.(*storagetest.Object).Name)
+
+		// b starts matching the selector
+		_, _, err = f.Update(ctx, "b", rest.DefaultUpdatedObjectInfo(&storagetest.Object{ObjectMeta: metav1.ObjectMeta{
+			Name: "b", Labels: map[string]string{"app": "x"},
+		}}), nil, nil, false, &metav1.UpdateOptions{})
+		assert.NoError(t, err)
+		ev = <-w.ResultChan()
+		assert.Equal(t, watch.Added, ev.Type)
+		assert.Equal(t, "b", ev.Object.(*storagetest.Object).Name)
+
+		// a stops matching the selector
+		_, _, err = f.Update(ctx, "a", rest.DefaultUpdatedObjectInfo(&storagetest.Object{ObjectMeta: metav1.ObjectMeta{
+			Name: "a", Labels: map[string]string{"app": "y"},
+		}}), nil, nil, false, &metav1.UpdateOptions{})
+		assert.NoError(t, err)
+		ev = <-w.ResultChan()
+		assert.Equal(t, watch.Deleted, ev.Type)
+		assert.Equal(t, "a", ev.Object.(*storagetest.Object).Name)
+		assert.Empty(t, w.ResultChan(), "events of other namespaces or objects should not be sent")
+	})
+	t.Run("delete collection should only delete matching objects", func(t *testing.T) {
+		deleted, err := f.DeleteCollection(ctx, nil, &metav1.DeleteOptions{}, selectX)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"default/b"}, names(deleted))
+
+		list, err := f.List(context.TODO(), nil)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"default/a", "other/c"}, names(list))
+	})
+}
+
+func TestFilepathRESTPagination(t *testing.T) {
+	f := newTestREST(t, t.TempDir())
+	// created out of order, in several namespaces
+	for _, key := range []string{"b/a", "a/b", "a/a-b", "a/a", "b/b"} {
+		ns, name, _ := strings.Cut(key, "/")
+		_, err := f.Create(genericapirequest.WithNamespace(context.TODO(), ns), &storagetest.Object{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+		}, nil, &metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+
+	var pages [][]string
+	var remaining []int64
+	options := &metainternalversion.ListOptions{Limit: 2}
+	for {
+		list, err := f.List(context.TODO(), options)
+		if !assert.NoError(t, err) {
+			return
+		}
+		var page []string
+		for _, item := range list.(*storagetest.ObjectList).Items {
+			page = append(page, item.Namespace+"/"+item.Name)
+		}
+		pages = append(pages, page)
+		if list.(*storagetest.ObjectList).Continue == "" {
+			assert.Nil(t, list.(*storagetest.ObjectList).RemainingItemCount)
+			break
+		}
+		remaining = append(remaining, *list.(*storagetest.ObjectList).RemainingItemCount)
+		options = &metainternalversion.ListOptions{Limit: 2, Continue: list.(*storagetest.ObjectList).Continue}
+	}
+	assert.Equal(t, [][]string{{"a/a", "a/a-b"}, {"a/b", "b/a"}, {"b/b"}}, pages)
+	assert.Equal(t, []int64{3, 1}, remaining)
+
+	_, err := f.List(context.TODO(), &metainternalversion.ListOptions{Continue: "invalid"})
+	assert.True(t, apierrors.IsBadRequest(err))
+}
+
+func TestFilepathRESTWatch(t *testing.T) {
+	root := t.TempDir()
+	f := newTestREST(t, root)
+	ctx := testContext()
+	for i := 0; i < 20; i++ {
+		_, err := f.Create(ctx, &storagetest.Object{ObjectMeta: metav1.ObjectMeta{GenerateName: "foo-"}}, nil, &metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+
+	t.Run("watch without resource version should send all existing objects", func(t *testing.T) {
+		w, err := f.Watch(ctx, &metainternalversion.ListOptions{})
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer w.Stop()
+		for i := 0; i < 20; i++ {
+			assert.Equal(t, watch.Added, (<-w.ResultChan()).Type)
+		}
+	})
+	t.Run("watch should resume from a resource version", func(t *testing.T) {
+		w, err := f.Watch(ctx, &metainternalversion.ListOptions{ResourceVersion: "18"})
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer w.Stop()
+		for _, rv := range []string{"19", "20"} {
+			ev := <-w.ResultChan()
+			assert.Equal(t, watch.Added, ev.Type)
+			assert.Equal(t, rv, ev.Object.(*storagetest.Object).ResourceVersion)
+		}
+		list, err := f.List(ctx, nil)
+		assert.NoError(t, err)
+		name := list.(*storagetest.ObjectList).Items[0].Name
+		_, _, err = f.Delete(ctx, name, nil, &metav1.DeleteOptions{})
+		assert.NoError(t, err)
+		ev := <-w.ResultChan()
+		assert.Equal(t, watch.Deleted, ev.Type)
+		assert.Equal(t, "21", ev.Object.(*storagetest.Object).ResourceVersion, "deletions should have their own resource version")
+	})
+	t.Run("watch from before a restart should be gone", func(t *testing.T) {
+		_, err := newTestREST(t, root).Watch(ctx, &metainternalversion.ListOptions{ResourceVersion: "18"})
+		assert.True(t, apierrors.IsResourceExpired(err))
+	})
+	t.Run("watcher ids should not collide after a watch is stopped", func(t *testing.T) {
+		opts := &metainternalversion.ListOptions{ResourceVersion: "21"}
+		w1, _ := f.Watch(ctx, opts)
+		w2, _ := f.Watch(ctx, opts)
+		w1.Stop()
+		w3, _ := f.Watch(ctx, opts)
+		defer w2.Stop()
+		defer w3.Stop()
+		_, err := f.Create(ctx, &storagetest.Object{ObjectMeta: metav1.ObjectMeta{Name: "bar"}}, nil, &metav1.CreateOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "bar", (<-w2.ResultChan()).Object.(*storagetest.Object).Name)
+		assert.Equal(t, "bar", (<-w3.ResultChan()).Object.(*storagetest.Object).Name)
+	})
+}
+
+func TestFilepathRESTNotifications(t *testing.T) {
+	root := t.TempDir()
+	f := newTestREST(t, root, WithFilesystemNotifications())
+	defer f.Destroy()
+	ctx := testContext()
+	w, err := f.Watch(ctx, &metainternalversion.ListOptions{ResourceVersion: "0"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer w.Stop()
+	next := func() watch.Event {
+		select {
+		case ev := <-w.ResultChan():
+			return ev
+		case <-time.After(wait.ForeverTestTimeout):
+			t.Fatal("timed out waiting for a watch event")
+			return watch.Event{}
+		}
+	}
+	dir := filepath.Join(f.objRootPath, "default")
+
+	_, err = f.Create(ctx, &storagetest.Object{ObjectMeta: metav1.ObjectMeta{Name: "api"}}, nil, &metav1.CreateOptions{})
+	assert.NoError(t, err)
+	ev := next()
+	assert.Equal(t, watch.Added, ev.Type)
+	assert.Equal(t, "api", ev.Object.(*storagetest.Object).Name)
+
+	t.Run("external creations should be sent as additions", func(t *testing.T) {
+		content := []byte(`{"apiVersion":"test.k8s.io/v1","kind":"Object","spec":"a"}`)
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "ext.json"), content, 0600))
+		ev := next()
+		assert.Equal(t, watch.Added, ev.Type)
+		obj := ev.Object.(*storagetest.Object)
+		assert.Equal(t, "ext", obj.Name)
+		assert.Equal(t, "default", obj.Namespace)
+		assert.NotEmpty(t, obj.UID)
+		assert.Equal(t, "2", obj.ResourceVersion)
+
+		stored, err := f.Get(ctx, "ext", &metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "2", stored.(*storagetest.Object).ResourceVersion, "the resource version should be kept")
+		assert.Equal(t, obj.UID, stored.(*storagetest.Object).UID)
+		written, err := os.ReadFile(filepath.Join(dir, "ext.json"))
+		assert.NoError(t, err)
+		assert.Equal(t, string(content), string(written), "the file should not be rewritten")
+	})
+	t.Run("invalid files should be reported and ignored", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0600))
+		warnings := &testWarnings{}
+		list, err := f.List(warning.WithWarningRecorder(ctx, warnings), nil)
+		assert.NoError(t, err)
+		assert.Len(t, list.(*storagetest.ObjectList).Items, 2)
+		assert.Len(t, *warnings, 1)
+	})
+	t.Run("external modifications should be sent as modifications", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "ext.json"),
+			[]byte(`{"apiVersion":"test.k8s.io/v1","kind":"Object","spec":"b"}`), 0600))
+		ev := next()
+		assert.Equal(t, watch.Modified, ev.Type)
+		assert.Equal(t, "b", ev.Object.(*storagetest.Object).Spec)
+		assert.Equal(t, int64(2), ev.Object.(*storagetest.Object).Generation)
+		assert.Equal(t, "3", ev.Object.(*storagetest.Object).ResourceVersion)
+	})
+	t.Run("external files in other formats should be kept in their format", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "other.yaml"),
+			[]byte("apiVersion: test.k8s.io/v1\nkind: Object\nspec: c\n"), 0600))
+		ev := next()
+		assert.Equal(t, watch.Added, ev.Type)
+		assert.Equal(t, "other", ev.Object.(*storagetest.Object).Name)
+		names, _ := filepath.Glob(filepath.Join(dir, "other.*"))
+		assert.Equal(t, []string{filepath.Join(dir, "other.yaml")}, names)
+	})
+	t.Run("external files should get resource versions on restart", func(t *testing.T) {
+		restarted := newTestREST(t, root)
+		obj, err := restarted.Get(ctx, "ext", &metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Empty(t, obj.(*storagetest.Object).ResourceVersion, "without notifications files are read as is")
+		restarted = newTestREST(t, root, WithFilesystemNotifications())
+		defer restarted.Destroy()
+		obj, err = restarted.Get(ctx, "ext", &metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, obj.(*storagetest.Object).ResourceVersion)
+		assert.NotEmpty(t, obj.(*storagetest.Object).UID)
+	})
+	t.Run("external deletions should be sent as deletions", func(t *testing.T) {
+		assert.NoError(t, os.Remove(filepath.Join(dir, "api.json")))
+		ev := next()
+		assert.Equal(t, watch.Deleted, ev.Type)
+		assert.Equal(t, "api", ev.Object.(*storagetest.Object).Name)
+	})
+}
+
+func TestFilepathRESTConcurrentUpdates(t *testing.T) {
+	root := t.TempDir()
+	f := newTestREST(t, root)
+	ctx := testContext()
+	_, err := f.Create(ctx, &storagetest.Object{ObjectMeta: metav1.ObjectMeta{Name: "counter"}, Spec: "0"}, nil, &metav1.CreateOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := f.Update(ctx, "counter", rest.DefaultUpdatedObjectInfo(nil,
+				func(_ context.Context, _, old runtime.Object) (runtime.Object, error) {
+					updated := old.DeepCopyObject().(*storagetest.Object)
+					n, _ := strconv.Atoi(updated.Spec)
+					updated.Spec = strconv.Itoa(n + 1)
+					// unconditional update
+					updated.ResourceVersion = ""
+					return updated, nil
+				}), nil, nil, false, &metav1.UpdateOptions{})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	obj, err := f.Get(ctx, "counter", &metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "20", obj.(*storagetest.Object).Spec, "no update should be lost")
+	temp, _ := filepath.Glob(filepath.Join(f.objRootPath, "default", ".*"))
+	assert.Empty(t, temp, "temporary files should be removed")
+}
+
+func TestFilepathRESTRecovery(t *testing.T) {
+	root := t.TempDir()
+	f := newTestREST(t, root)
+	ctx := testContext()
+	_, err := f.Create(ctx, &storagetest.Object{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}, nil, &metav1.CreateOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	// a write interrupted by a crash
+	leftover := filepath.Join(f.objRootPath, "default", ".foo.json"+tempFileSuffix+"123")
+	assert.NoError(t, os.WriteFile(leftover, []byte(`{"apiVersion":`), 0600))
+
+	f = newTestREST(t, root)
+	_, err = os.Stat(leftover)
+	assert.True(t, os.IsNotExist(err), "temporary files should be removed at startup")
+	list, err := f.List(ctx, nil)
+	assert.NoError(t, err)
+	assert.Len(t, list.(*storagetest.ObjectList).Items, 1)
+}
+
+func TestFilepathRESTYAML(t *testing.T) {
+	root := t.TempDir()
+	ctx := testContext()
+	_, err := newTestREST(t, root).Create(ctx, &storagetest.Object{ObjectMeta: metav1.ObjectMeta{Name: "old"}, Spec: "a"},
+		nil, &metav1.CreateOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(storagetest.GroupVersion, &storagetest.Object{}, &storagetest.ObjectList{})
+	metav1.AddToGroupVersion(scheme, storagetest.GroupVersion)
+	codec, _, err := serverstorage.NewStorageCodec(serverstorage.StorageCodecConfig{
+		StorageMediaType:  runtime.ContentTypeYAML,
+		StorageSerializer: serializer.NewCodecFactory(scheme),
+		StorageVersion:    storagetest.GroupVersion,
+		MemoryVersion:     storagetest.GroupVersion,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	f := newTestRESTWithCodec(t, root, codec, WithFileExtension(".yaml"))
+	dir := filepath.Join(f.objRootPath, "default")
+
+	_, err = f.Create(ctx, &storagetest.Object{ObjectMeta: metav1.ObjectMeta{Name: "new"}, Spec: "b"}, nil, &metav1.CreateOptions{})
+	assert.NoError(t, err)
+	content, err := os.ReadFile(filepath.Join(dir, "new.yaml"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "spec: b\n")
+
+	t.Run("objects in other formats should be read", func(t *testing.T) {
+		list, err := f.List(ctx, nil)
+		assert.NoError(t, err)
+		assert.Len(t, list.(*storagetest.ObjectList).Items, 2)
+		obj, err := f.Get(ctx, "old", &metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "a", obj.(*storagetest.Object).Spec)
+		_, err = f.Create(ctx, &storagetest.Object{ObjectMeta: metav1.ObjectMeta{Name: "old"}}, nil, &metav1.CreateOptions{})
+		assert.True(t, apierrors.IsAlreadyExists(err))
+	})
+	t.Run("updates should migrate objects to the format of the storage", func(t *testing.T) {
+		_, _, err := f.Update(ctx, "old", rest.DefaultUpdatedObjectInfo(&storagetest.Object{
+			ObjectMeta: metav1.ObjectMeta{Name: "old"}, Spec: "c",
+		}), nil, nil, false, &metav1.UpdateOptions{})
+		assert.NoError(t, err)
+		names, _ := filepath.Glob(filepath.Join(dir, "old.*"))
+		assert.Equal(t, []string{filepath.Join(dir, "old.yaml")}, names)
+	})
+	t.Run("the format of the storage should take precedence", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "new.json"),
+			[]byte(`{"apiVersion":"test.k8s.io/v1","kind":"Object","metadata":{"name":"new"},"spec":"stale"}`), 0600))
+		list, err := f.List(ctx, nil)
+		assert.NoError(t, err)
+		if assert.Len(t, list.(*storagetest.ObjectList).Items, 2) {
+			assert.Equal(t, "b", list.(*storagetest.ObjectList).Items[0].Spec)
+		}
+	})
+	t.Run("updates should remove the copies in other formats", func(t *testing.T) {
+		_, _, err := f.Update(ctx, "new", rest.DefaultUpdatedObjectInfo(&storagetest.Object{
+			ObjectMeta: metav1.ObjectMeta{Name: "new"}, Spec: "d",
+		}), nil, nil, false, &metav1.UpdateOptions{})
+		assert.NoError(t, err)
+		names, _ := filepath.Glob(filepath.Join(dir, "new.*"))
+		assert.Equal(t, []string{filepath.Join(dir, "new.yaml")}, names)
+	})
+	t.Run("deletes should remove the copies in every format", func(t *testing.T) {
+		for _, name := range []string{"new.json", "new.yml"} {
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, name),
+				[]byte(`{"apiVersion":"test.k8s.io/v1","kind":"Object","metadata":{"name":"new"},"spec":"stale"}`), 0600))
+		}
+		_, _, err := f.Delete(ctx, "new", nil, &metav1.DeleteOptions{})
+		assert.NoError(t, err)
+		names, _ := filepath.Glob(filepath.Join(dir, "new.*"))
+		assert.Empty(t, names)
+		_, err = f.Get(ctx, "new", &metav1.GetOptions{})
+		assert.True(t, apierrors.IsNotFound(err), "the copies should not be served: %v", err)
+	})
+}
+
+func TestFilepathRESTEncryption(t *testing.T) {
+	root := t.TempDir()
+	ctx := testContext()
+	_, err := newTestREST(t, root).Create(ctx, &storagetest.Object{ObjectMeta: metav1.ObjectMeta{Name: "plain"}, Spec: "a"},
+		nil, &metav1.CreateOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	key1 := apiserverv1.Key{Name: "key1", Secret: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))}
+	key2 := apiserverv1.Key{Name: "key2", Secret: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))}
+	newEncryptedREST := func(keys ...apiserverv1.Key) *filepathREST {
+		transformers, err := encryption.NewResourceTransformers(context.TODO(), &apiserverv1.EncryptionConfiguration{
+			Resources: []apiserverv1.ResourceConfiguration{{
+				Resources: []string{"testobjects.test.k8s.io"},
+				Providers: []apiserverv1.ProviderConfiguration{
+					{AESCBC: &apiserverv1.AESConfiguration{Keys: keys}},
+					{Identity: &apiserverv1.IdentityConfiguration{}},
+				},
+			}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return newTestREST(t, root, WithEncryption(transformers))
+	}
+
+	f := newEncryptedREST(key1)
+	_, err = f.Create(ctx, &storagetest.Object{ObjectMeta: metav1.ObjectMeta{Name: "secret"}, Spec: "b"}, nil, &metav1.CreateOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	content, err := os.ReadFile(filepath.Join(f.objRootPath, "default", "secret.json"))
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(content, []byte("k8s:enc:aescbc:v1:key1:")), "expected the file encrypted")
+	assert.NotContains(t, string(content), `"spec"`)
+
+	t.Run("unencrypted files should be read with the identity provider", func(t *testing.T) {
+		list, err := f.List(ctx, nil)
+		assert.NoError(t, err)
+		assert.Len(t, list.(*storagetest.ObjectList).Items, 2)
+	})
+	t.Run("objects should be read with the old keys after a rotation", func(t *testing.T) {
+		f := newEncryptedREST(key2, key1)
+		obj, err := f.Get(ctx, "secret", &metav1.GetOptions{})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "b", obj.(*storagetest.Object).Spec)
+		}
+		_, _, err = f.Update(ctx, "secret", rest.DefaultUpdatedObjectInfo(obj), nil, nil, false, &metav1.UpdateOptions{})
+		assert.NoError(t, err)
+		content, err := os.ReadFile(filepath.Join(f.objRootPath, "default", "secret.json"))
+		assert.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(content, []byte("k8s:enc:aescbc:v1:key2:")), "expected the file re-encrypted")
+	})
+}
+
+func TestFilepathRESTDryRun(t *testing.T) {
+	root := t.TempDir()
+	f := newTestREST(t, root)
+	ctx := testContext()
+	_, err := f.Create(ctx, &storagetest.Object{ObjectMeta: metav1.ObjectMeta{Name: "foo"}, Spec: "a"}, nil, &metav1.CreateOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	w, err := f.Watch(ctx, &metainternalversion.ListOptions{ResourceVersion: "1"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer w.Stop()
+	dryRun := []string{metav1.DryRunAll}
+	validated := 0
+	validate := func(context.Context, runtime.Object) error {
+		validated++
+		return nil
+	}
+
+	created, err := f.Create(genericapirequest.WithNamespace(context.TODO(), "other"),
+		&storagetest.Object{ObjectMeta: metav1.ObjectMeta{Name: "bar"}}, validate, &metav1.CreateOptions{DryRun: dryRun})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, created.(*storagetest.Object).UID)
+	assert.Empty(t, created.(*storagetest.Object).ResourceVersion)
+	_, err = f.Create(ctx, &storagetest.Object{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}, nil, &metav1.CreateOptions{DryRun: dryRun})
+	assert.True(t, apierrors.IsAlreadyExists(err), "dry-run creates should still be checked")
+
+	updated, _, err := f.Update(ctx, "foo", rest.DefaultUpdatedObjectInfo(&storagetest.Object{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo"}, Spec: "b",
+	}), nil, func(ctx context.Context, obj, _ runtime.Object) error {
+		return validate(ctx, obj)
+	}, false, &metav1.UpdateOptions{DryRun: dryRun})
+	assert.NoError(t, err)
+	assert.Equal(t, "b", updated.(*storagetest.Object).Spec)
+	assert.Equal(t, "1", updated.(*storagetest.Object).ResourceVersion)
+	assert.Equal(t, int64(2), updated.(*storagetest.Object).Generation)
+
+	_, _, err = f.Delete(ctx, "foo", validate, &metav1.DeleteOptions{DryRun: dryRun})
+	assert.NoError(t, err)
+	_, err = f.DeleteCollection(ctx, validate, &metav1.DeleteOptions{DryRun: dryRun}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, validated, "dry-run requests should be validated")
+
+	obj, err := f.Get(ctx, "foo", &metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "a", obj.(*storagetest.Object).Spec)
+	assert.Equal(t, "1", obj.(*storagetest.Object).ResourceVersion)
+	assert.NoDirExists(t, filepath.Join(f.objRootPath, "other"))
+	assert.Equal(t, uint64(1), f.currentResourceVersion())
+	select {
+	case ev := <-w.ResultChan():
+		t.Errorf("unexpected event %v", ev)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+type testWarnings []string
+
+func (w *testWarnings) AddWarning(_, text string) {
+	*w = append(*w, text)
+}
@@ -0,0 +1,41 @@
+package filepath
+
+import "sync"
+
+// keyLocks holds a mutex for each key in use.  The zero value is ready to use.
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	sync.Mutex
+	// refs is the number of callers holding or waiting for the lock
+	refs int
+}
+
+// lock locks key and returns the function unlocking it.
+func (k *keyLocks) lock(key string) func() {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = map[string]*keyLock{}
+	}
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyLock{}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		l.refs--
+		if l.refs == 0 {
+			delete(k.locks, key)
+		}
+	}
+}
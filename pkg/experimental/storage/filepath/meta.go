@@ -0,0 +1,138 @@
+package filepath
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/storage/internal/broadcaster"
+)
+
+// commit assigns the next resource version to obj, persists it to filename and records the change for the
+// watchers.  Commits are serialized so that changes are recorded in resource version order.  oldObj is the
+// previous state of the object for watch.Modified events.
+func (f *filepathREST) commit(eventType watch.EventType, filename string, obj, oldObj runtime.Object, persist func() error) error {
+	f.muCommit.Lock()
+	defer f.muCommit.Unlock()
+	return f.commitLocked(eventType, filename, obj, oldObj, persist)
+}
+
+func (f *filepathREST) commitLocked(eventType watch.EventType, filename string, obj, oldObj runtime.Object, persist func() error) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	rv := f.resourceVersion + 1
+	accessor.SetResourceVersion(strconv.FormatUint(rv, 10))
+	// the resource version is saved first, so that it isn't reused after a restart even if it was the resource
+	// version of an object deleted since
+	if err := f.saveResourceVersion(rv); err != nil {
+		return err
+	}
+	if err := persist(); err != nil {
+		return err
+	}
+	f.resourceVersion = rv
+	recorded := obj.DeepCopyObject()
+	if f.known != nil {
+		f.remember(filename, recorded, eventType == watch.Deleted)
+	}
+	f.broadcaster.Action(broadcaster.Event{
+		Type:            eventType,
+		Object:          recorded,
+		OldObject:       oldObj,
+		ResourceVersion: rv,
+	})
+	return nil
+}
+
+// currentResourceVersion returns the resource version of the last write.
+func (f *filepathREST) currentResourceVersion() uint64 {
+	return f.broadcaster.ResourceVersion()
+}
+
+// resourceVersionFileName is the file of the storage root saving the resource version of the last write.  Being a
+// dot file, it is never read as an object.
+const resourceVersionFileName = ".resourceversion"
+
+// saveResourceVersion saves rv as the resource version of the last write.
+func (f *filepathREST) saveResourceVersion(rv uint64) error {
+	return writeFile(filepath.Join(f.objRootPath, resourceVersionFileName), []byte(strconv.FormatUint(rv, 10)))
+}
+
+// initResourceVersion resumes the resource versions from the saved resource version of the last write, or from
+// the highest one found in the stored objects if higher, e.g. for objects written by another process.
+func (f *filepathREST) initResourceVersion() {
+	content, err := os.ReadFile(filepath.Join(f.objRootPath, resourceVersionFileName))
+	if err == nil {
+		rv, err := strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+		if err != nil {
+			klog.Errorf("Ignoring invalid resource version file in %s: %v", f.objRootPath, err)
+		} else {
+			f.resourceVersion = rv
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		klog.Errorf("Ignoring unreadable resource version file in %s: %v", f.objRootPath, err)
+	}
+	// the files written by other processes, without resource version, are accepted once the highest resource version
+	// is known
+	type unversionedFile struct {
+		path    string
+		obj     runtime.Object
+		content []byte
+	}
+	var unversioned []unversionedFile
+	contents := map[string][]byte{}
+	readFunc := func(path string) (runtime.Object, error) {
+		content, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return nil, err
+		}
+		contents[path] = content
+		return decode(f.codec, content, f.newFunc)
+	}
+	_ = visitDir(f.objRootPath, readFunc, func(path string, obj runtime.Object) error {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return nil
+		}
+		if f.known != nil {
+			if accessor.GetResourceVersion() == "" {
+				unversioned = append(unversioned, unversionedFile{path: path, obj: obj, content: contents[path]})
+				return nil
+			}
+			f.known[path] = knownFile{obj: obj, content: contents[path]}
+		}
+		rv, err := strconv.ParseUint(accessor.GetResourceVersion(), 10, 64)
+		if err == nil && rv > f.resourceVersion {
+			f.resourceVersion = rv
+		}
+		return nil
+	}, func(path string, err error) {
+		klog.Errorf("Ignoring invalid file %s: %v", path, err)
+	})
+	for _, file := range unversioned {
+		if err := f.acceptFile(file.path, file.obj, nil); err != nil {
+			klog.Errorf("Ignoring invalid file %s: %v", file.path, err)
+			continue
+		}
+		f.resourceVersion++
+		if accessor, err := meta.Accessor(file.obj); err == nil {
+			accessor.SetResourceVersion(strconv.FormatUint(f.resourceVersion, 10))
+		}
+		f.known[file.path] = knownFile{obj: file.obj, content: file.content}
+	}
+	if len(unversioned) > 0 {
+		if err := f.saveResourceVersion(f.resourceVersion); err != nil {
+			klog.Errorf("Unable to save the resource version in %s: %v", f.objRootPath, err)
+		}
+	}
+}
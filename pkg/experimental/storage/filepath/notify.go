@@ -0,0 +1,252 @@
+package filepath
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/storage/internal/storageutil"
+)
+
+// knownFile is the last state of a file, as written by the storage or accepted from another process.  Files changed
+// by other processes aren't rewritten, so that edits made meanwhile are never lost: the metadata set by the storage
+// on their object, e.g. its resource version, is only kept here.
+type knownFile struct {
+	obj     runtime.Object
+	content []byte
+}
+
+// remember records the state of a file after a commit, so that notifications for writes made by the storage itself
+// are ignored.
+func (f *filepathREST) remember(filename string, obj runtime.Object, deleted bool) {
+	if deleted {
+		delete(f.known, filename)
+		return
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		// the next notification will compare different
+		content = nil
+	}
+	f.known[filename] = knownFile{obj: obj, content: content}
+}
+
+// startNotifications watches the root directory and its subdirectories.
+func (f *filepathREST) startNotifications() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	f.fsWatch = w
+	if err := f.watchDir(f.objRootPath, false); err != nil {
+		_ = w.Close()
+		return err
+	}
+	go f.processNotifications(w)
+	return nil
+}
+
+// watchDir adds dirname and its subdirectories to the watcher.  If sync is true the files found are synchronized,
+// as they may have been created before the directory was watched.
+func (f *filepathREST) watchDir(dirname string, sync bool) error {
+	return filepath.WalkDir(dirname, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path != dirname && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return f.fsWatch.Add(path)
+		}
+		if sync && isObjectFile(d.Name()) {
+			f.syncFile(path)
+		}
+		return nil
+	})
+}
+
+func (f *filepathREST) processNotifications(w *fsnotify.Watcher) {
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			f.handleNotification(ev)
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			klog.Errorf("Error watching %s: %v", f.objRootPath, err)
+		}
+	}
+}
+
+func (f *filepathREST) handleNotification(ev fsnotify.Event) {
+	if isObjectFile(filepath.Base(ev.Name)) {
+		f.syncFile(ev.Name)
+		return
+	}
+	if ev.Has(fsnotify.Create) {
+		if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+			if err := f.watchDir(ev.Name, true); err != nil {
+				klog.Errorf("Unable to watch %s: %v", ev.Name, err)
+			}
+		}
+		return
+	}
+	if ev.Has(fsnotify.Remove) || ev.Has(fsnotify.Rename) {
+		// a directory moved away doesn't notify the removal of its files
+		for _, path := range f.knownFilesUnder(ev.Name) {
+			f.syncFile(path)
+		}
+	}
+}
+
+func (f *filepathREST) knownFilesUnder(dirname string) []string {
+	f.muCommit.Lock()
+	defer f.muCommit.Unlock()
+	var paths []string
+	prefix := dirname + string(filepath.Separator)
+	for path := range f.known {
+		if strings.HasPrefix(path, prefix) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// readObject reads the object stored in path.  The object last accepted from a file changed by another process is
+// returned as long as the file is unchanged since, with the metadata set by the storage.
+func (f *filepathREST) readObject(path string) (runtime.Object, error) {
+	content, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	if f.known != nil {
+		f.muCommit.Lock()
+		known, ok := f.known[path]
+		f.muCommit.Unlock()
+		if ok && bytes.Equal(content, known.content) {
+			return known.obj.DeepCopyObject(), nil
+		}
+	}
+	return decode(f.codec, content, f.newFunc)
+}
+
+// syncFile records the current state of a file changed by another process.  The file is left as is, in its format.
+func (f *filepathREST) syncFile(path string) {
+	filename := strings.TrimSuffix(path, filepath.Ext(path)) + f.extension
+	unlock := f.locks.lock(filename)
+	defer unlock()
+	f.muCommit.Lock()
+	defer f.muCommit.Unlock()
+
+	old, isKnown := f.known[path]
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if !isKnown {
+			return
+		}
+		if err := f.commitLocked(watch.Deleted, path, old.obj.DeepCopyObject(), nil, func() error {
+			return nil
+		}); err != nil {
+			klog.Errorf("Unable to record the deletion of %s: %v", path, err)
+		}
+		return
+	}
+	if err != nil {
+		klog.Errorf("Unable to read %s: %v", path, err)
+		return
+	}
+	if f.objectFile(path) != path {
+		// also stored in another format, which takes precedence
+		return
+	}
+	if isKnown && bytes.Equal(content, old.content) {
+		// written by the storage
+		return
+	}
+
+	obj, err := decode(f.codec, content, f.newFunc)
+	if errors.Is(err, errEmptyFile) {
+		// being rewritten, the write will be notified
+		return
+	}
+	if err != nil {
+		klog.Errorf("Ignoring invalid file %s: %v", path, err)
+		return
+	}
+	if err := f.acceptFile(path, obj, old.obj); err != nil {
+		klog.Errorf("Ignoring invalid file %s: %v", path, err)
+		return
+	}
+	eventType := watch.Added
+	if isKnown {
+		eventType = watch.Modified
+	}
+	if err := f.commitLocked(eventType, path, obj, old.obj, func() error {
+		return nil
+	}); err != nil {
+		klog.Errorf("Unable to record the change of %s: %v", path, err)
+		return
+	}
+	// the content read is the one accepted, even if the file changed again since
+	f.known[path] = knownFile{obj: obj.DeepCopyObject(), content: content}
+}
+
+// acceptFile validates an object changed by another process against its path, and sets the metadata fields owned
+// by the storage.  oldObj is the last known state of the object, if any.
+func (f *filepathREST) acceptFile(path string, obj, oldObj runtime.Object) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(f.objRootPath, path)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSuffix(filepath.Base(rel), filepath.Ext(rel))
+	ns := ""
+	if dir := filepath.Dir(rel); dir != "." {
+		ns = dir
+	}
+	switch {
+	case f.isNamespaced && (ns == "" || strings.ContainsRune(ns, filepath.Separator)):
+		return fmt.Errorf("expected the file in a namespace directory")
+	case !f.isNamespaced && ns != "":
+		return fmt.Errorf("expected the file in the root directory")
+	}
+
+	if accessor.GetName() == "" {
+		accessor.SetName(name)
+	}
+	if accessor.GetName() != name {
+		return fmt.Errorf("name %q doesn't match the file name", accessor.GetName())
+	}
+	if f.isNamespaced && accessor.GetNamespace() == "" {
+		accessor.SetNamespace(ns)
+	}
+	if accessor.GetNamespace() != ns {
+		return fmt.Errorf("namespace %q doesn't match the directory", accessor.GetNamespace())
+	}
+
+	if oldObj == nil {
+		if accessor.GetUID() == "" {
+			storageutil.InitObjectMeta(accessor)
+		}
+		return nil
+	}
+	return storageutil.CarryOverObjectMeta(obj, oldObj)
+}
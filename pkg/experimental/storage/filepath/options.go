@@ -0,0 +1,42 @@
+package filepath
+
+import (
+	"k8s.io/apiserver/pkg/storage/value"
+)
+
+// Option configures the storage returned by NewFilepathREST.
+type Option func(*filepathREST)
+
+// WithFilesystemNotifications watches the root directory for changes made by other processes -- e.g. GitOps
+// tooling editing the files directly -- and turns the creation, modification and deletion of object files into
+// watch events.  Changed files are validated before being accepted: files which can't be decoded, or whose name
+// or namespace don't match their path, are logged and ignored.
+//
+// Accepted changes get the next resource version.  Files are never written back, so that edits made meanwhile
+// aren't lost: the resource version, and the metadata initialized by the storage for new objects, are kept in memory
+// until the object is next written through the storage.  Files without resource version get a new one on restart.
+func WithFilesystemNotifications() Option {
+	return func(f *filepathREST) {
+		f.notify = true
+	}
+}
+
+// WithFileExtension sets the extension of the files written by the storage, which must match the format of its
+// codec: ".json", ".yaml" or ".pb".  It defaults to ".json".  Files with the other extensions are still read, and
+// rewritten in the format of the storage when their object is updated.
+func WithFileExtension(ext string) Option {
+	return func(f *filepathREST) {
+		f.extension = ext
+	}
+}
+
+// WithEncryption encrypts the files at rest with the transformer of the resource in transformers, e.g. the ones
+// returned by encryption.NewResourceTransformers for an EncryptionConfiguration.  Objects are encrypted with the
+// first provider of the resource and decrypted with any of them: after a key rotation, objects are re-encrypted
+// with the new key when they are next written.  Files written before the encryption was enabled, or by hand, are
+// read only if the identity provider is among the providers of the resource.
+func WithEncryption(transformers value.ResourceTransformers) Option {
+	return func(f *filepathREST) {
+		f.transformer = transformers.TransformerForResource(f.groupResource)
+	}
+}
@@ -0,0 +1,47 @@
+package filepath
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/storage/value"
+)
+
+// transformingCodec transforms the content of the files, e.g. to encrypt it, around a codec.
+type transformingCodec struct {
+	runtime.Codec
+	transformer value.Transformer
+	dataCtx     value.Context
+}
+
+func (c *transformingCodec) Encode(obj runtime.Object, w io.Writer) error {
+	buf := new(bytes.Buffer)
+	if err := c.Codec.Encode(obj, buf); err != nil {
+		return err
+	}
+	data, err := c.transformer.TransformToStorage(context.TODO(), buf.Bytes(), c.dataCtx)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (c *transformingCodec) Decode(
+	data []byte,
+	defaults *schema.GroupVersionKind,
+	into runtime.Object,
+) (runtime.Object, *schema.GroupVersionKind, error) {
+	data, _, err := c.transformer.TransformFromStorage(context.TODO(), data, c.dataCtx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.Codec.Decode(data, defaults, into)
+}
+
+func (c *transformingCodec) Identifier() runtime.Identifier {
+	return runtime.Identifier("transforming(" + string(c.Codec.Identifier()) + ")")
+}
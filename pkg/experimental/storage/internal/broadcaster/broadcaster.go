@@ -0,0 +1,273 @@
+// Package broadcaster provides the watch implementation shared by the experimental storages.
+package broadcaster
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const (
+	// DefaultHistorySize is the default number of events kept to resume watches from.
+	DefaultHistorySize = 1000
+	// DefaultBufferSize is the default number of events buffered for each watcher.
+	DefaultBufferSize = 100
+	// DefaultBookmarkInterval is the default interval between bookmarks sent to watchers which allow them.
+	DefaultBookmarkInterval = time.Minute
+)
+
+// Event is a change recorded by a storage.
+type Event struct {
+	Type watch.EventType
+	// Object is the object after the change, or the last state of the object for watch.Deleted events.
+	Object runtime.Object
+	// OldObject is the object before the change for watch.Modified events.
+	OldObject runtime.Object
+	// ResourceVersion is the resource version of the change.
+	ResourceVersion uint64
+}
+
+// WatchOptions configures a watch.
+type WatchOptions struct {
+	// ResourceVersion is the resource version to start the watch from, only changes with a greater resource
+	// version are sent.
+	ResourceVersion uint64
+	// InitialObjects are sent as watch.Added events before the changes following ResourceVersion.
+	InitialObjects []runtime.Object
+	// Filter returns true for the objects to watch.  All objects are watched if nil.
+	Filter func(obj runtime.Object) bool
+	// Bookmarks enables sending bookmarks periodically.
+	Bookmarks bool
+	// InitialEventsEnd sends a bookmark annotated with metav1.InitialEventsAnnotationKey after the initial objects.
+	InitialEventsEnd bool
+}
+
+// New returns a Broadcaster whose first event follows resourceVersion.  newFunc returns the empty objects used
+// for bookmarks.
+func New(resourceVersion uint64, newFunc func() runtime.Object) *Broadcaster {
+	return &Broadcaster{
+		HistorySize:      DefaultHistorySize,
+		BufferSize:       DefaultBufferSize,
+		BookmarkInterval: DefaultBookmarkInterval,
+		newFunc:          newFunc,
+		resourceVersion:  resourceVersion,
+		oldestResumable:  resourceVersion,
+		watchers:         map[int64]*watcher{},
+	}
+}
+
+// Broadcaster sends the events recorded by a storage to its watchers, without blocking on slow watchers, and keeps
+// a window of recent events so that watches can resume from a resource version.
+//
+// A watcher which doesn't keep up with the events is stopped once its buffer is full.  Clients then restart the
+// watch from the last resource version they received.
+type Broadcaster struct {
+	// HistorySize is the number of events kept to resume watches from.
+	HistorySize int
+	// BufferSize is the number of events buffered for each watcher.
+	BufferSize int
+	// BookmarkInterval is the interval between bookmarks.
+	BookmarkInterval time.Duration
+
+	newFunc func() runtime.Object
+
+	mu              sync.Mutex
+	resourceVersion uint64
+	oldestResumable uint64
+	history         []Event
+	nextID          int64
+	watchers        map[int64]*watcher
+}
+
+// ResourceVersion returns the resource version of the last recorded event.
+func (b *Broadcaster) ResourceVersion() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.resourceVersion
+}
+
+// Action records an event and sends it to the watchers.  Events must be recorded in resource version order.
+func (b *Broadcaster) Action(ev Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.resourceVersion = ev.ResourceVersion
+	b.history = append(b.history, ev)
+	if len(b.history) > b.HistorySize {
+		b.oldestResumable = b.history[0].ResourceVersion
+		b.history = b.history[1:]
+	}
+	for id, w := range b.watchers {
+		select {
+		case w.input <- ev:
+		default:
+			// the watcher is too slow, it has to restart from the last event it received
+			delete(b.watchers, id)
+			close(w.input)
+		}
+	}
+}
+
+// Watch starts a watch.  An apierrors.NewResourceExpired error is returned if the events following
+// options.ResourceVersion are not in the history anymore.
+func (b *Broadcaster) Watch(options WatchOptions) (watch.Interface, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if options.ResourceVersion < b.oldestResumable {
+		return nil, apierrors.NewResourceExpired(
+			fmt.Sprintf("too old resource version: %d (%d)", options.ResourceVersion, b.oldestResumable))
+	}
+
+	w := &watcher{
+		b:       b,
+		id:      b.nextID,
+		options: options,
+		rv:      options.ResourceVersion,
+		input:   make(chan Event, b.BufferSize),
+		result:  make(chan watch.Event),
+		done:    make(chan struct{}),
+	}
+	b.nextID++
+	b.watchers[w.id] = w
+
+	i := sort.Search(len(b.history), func(i int) bool {
+		return b.history[i].ResourceVersion > options.ResourceVersion
+	})
+	replay := append([]Event(nil), b.history[i:]...)
+	go w.run(replay)
+	return w, nil
+}
+
+// Shutdown stops all the watchers.
+func (b *Broadcaster) Shutdown() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id, w := range b.watchers {
+		delete(b.watchers, id)
+		close(w.input)
+	}
+}
+
+type watcher struct {
+	b       *Broadcaster
+	id      int64
+	options WatchOptions
+	// rv is the resource version of the last event processed by the watcher
+	rv uint64
+
+	input    chan Event
+	result   chan watch.Event
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func (w *watcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.done)
+	})
+	w.b.mu.Lock()
+	defer w.b.mu.Unlock()
+	if _, ok := w.b.watchers[w.id]; ok {
+		delete(w.b.watchers, w.id)
+		close(w.input)
+	}
+}
+
+func (w *watcher) ResultChan() <-chan watch.Event {
+	return w.result
+}
+
+func (w *watcher) run(replay []Event) {
+	defer close(w.result)
+	for _, obj := range w.options.InitialObjects {
+		if !w.send(watch.Event{Type: watch.Added, Object: obj}) {
+			return
+		}
+	}
+	if w.options.InitialEventsEnd && !w.sendBookmark(true) {
+		return
+	}
+	for _, ev := range replay {
+		if !w.process(ev) {
+			return
+		}
+	}
+
+	var bookmarks <-chan time.Time
+	if w.options.Bookmarks {
+		ticker := time.NewTicker(w.b.BookmarkInterval)
+		defer ticker.Stop()
+		bookmarks = ticker.C
+	}
+	for {
+		select {
+		case ev, ok := <-w.input:
+			if !ok || !w.process(ev) {
+				return
+			}
+		case <-bookmarks:
+			if !w.sendBookmark(false) {
+				return
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// process sends the event if it matches the filter of the watcher.  Modifications are sent as additions or
+// deletions when the object starts or stops matching the filter.
+func (w *watcher) process(ev Event) bool {
+	w.rv = ev.ResourceVersion
+	cur := w.matches(ev.Object)
+	if ev.Type != watch.Modified {
+		if !cur {
+			return true
+		}
+		return w.send(watch.Event{Type: ev.Type, Object: ev.Object})
+	}
+	old := ev.OldObject != nil && w.matches(ev.OldObject)
+	switch {
+	case cur && old:
+		return w.send(watch.Event{Type: watch.Modified, Object: ev.Object})
+	case cur:
+		return w.send(watch.Event{Type: watch.Added, Object: ev.Object})
+	case old:
+		return w.send(watch.Event{Type: watch.Deleted, Object: ev.OldObject})
+	}
+	return true
+}
+
+func (w *watcher) matches(obj runtime.Object) bool {
+	return w.options.Filter == nil || w.options.Filter(obj)
+}
+
+// sendBookmark sends an empty object carrying the resource version of the last event processed by the watcher.
+func (w *watcher) sendBookmark(initialEventsEnd bool) bool {
+	obj := w.b.newFunc()
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return true
+	}
+	accessor.SetResourceVersion(strconv.FormatUint(w.rv, 10))
+	if initialEventsEnd {
+		accessor.SetAnnotations(map[string]string{metav1.InitialEventsAnnotationKey: "true"})
+	}
+	return w.send(watch.Event{Type: watch.Bookmark, Object: obj})
+}
+
+func (w *watcher) send(ev watch.Event) bool {
+	select {
+	case w.result <- ev:
+		return true
+	case <-w.done:
+		return false
+	}
+}
@@ -0,0 +1,141 @@
+package broadcaster
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func newTestBroadcaster() *Broadcaster {
+	return New(10, func() runtime.Object { return &metav1.PartialObjectMetadata{} })
+}
+
+func object(name string, rv uint64) *metav1.PartialObjectMetadata {
+	return &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{
+		Name: name, ResourceVersion: strconv.FormatUint(rv, 10),
+	}}
+}
+
+func receive(t *testing.T, w watch.Interface) watch.Event {
+	select {
+	case ev := <-w.ResultChan():
+		return ev
+	case <-time.After(wait.ForeverTestTimeout):
+		t.Fatal("timed out waiting for an event")
+	}
+	return watch.Event{}
+}
+
+func TestBroadcasterResume(t *testing.T) {
+	b := newTestBroadcaster()
+	b.HistorySize = 2
+	for rv := uint64(11); rv <= 13; rv++ {
+		b.Action(Event{Type: watch.Added, Object: object("foo", rv), ResourceVersion: rv})
+	}
+	assert.Equal(t, uint64(13), b.ResourceVersion())
+
+	t.Run("watch should resume from the history", func(t *testing.T) {
+		w, err := b.Watch(WatchOptions{ResourceVersion: 11})
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer w.Stop()
+		assert.Equal(t, "12", receive(t, w).Object.(*metav1.PartialObjectMetadata).ResourceVersion)
+		assert.Equal(t, "13", receive(t, w).Object.(*metav1.PartialObjectMetadata).ResourceVersion)
+	})
+	t.Run("watch should fail with gone if the history is too short", func(t *testing.T) {
+		_, err := b.Watch(WatchOptions{ResourceVersion: 10})
+		assert.True(t, apierrors.IsResourceExpired(err))
+	})
+}
+
+func TestBroadcasterFilter(t *testing.T) {
+	b := newTestBroadcaster()
+	w, err := b.Watch(WatchOptions{
+		ResourceVersion: 10,
+		InitialObjects:  []runtime.Object{object("foo", 10)},
+		Filter: func(obj runtime.Object) bool {
+			return obj.(*metav1.PartialObjectMetadata).Labels["app"] == "x"
+		},
+		InitialEventsEnd: true,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer w.Stop()
+	assert.Equal(t, watch.Added, receive(t, w).Type)
+	ev := receive(t, w)
+	assert.Equal(t, watch.Bookmark, ev.Type)
+	assert.Equal(t, "true", ev.Object.(*metav1.PartialObjectMetadata).Annotations[metav1.InitialEventsAnnotationKey])
+
+	matching := object("bar", 12)
+	matching.Labels = map[string]string{"app": "x"}
+	b.Action(Event{Type: watch.Added, Object: object("baz", 11), ResourceVersion: 11})
+	b.Action(Event{Type: watch.Modified, Object: matching, OldObject: object("bar", 10), ResourceVersion: 12})
+	b.Action(Event{Type: watch.Modified, Object: object("bar", 13), OldObject: matching, ResourceVersion: 13})
+
+	ev = receive(t, w)
+	assert.Equal(t, watch.Added, ev.Type, "modified objects starting to match should be added")
+	assert.Equal(t, "12", ev.Object.(*metav1.PartialObjectMetadata).ResourceVersion)
+	ev = receive(t, w)
+	assert.Equal(t, watch.Deleted, ev.Type, "modified objects no longer matching should be deleted")
+}
+
+func TestBroadcasterBookmarks(t *testing.T) {
+	b := newTestBroadcaster()
+	b.BookmarkInterval = 10 * time.Millisecond
+	w, err := b.Watch(WatchOptions{ResourceVersion: 10, Bookmarks: true, Filter: func(runtime.Object) bool { return false }})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer w.Stop()
+	b.Action(Event{Type: watch.Added, Object: object("foo", 11), ResourceVersion: 11})
+	for {
+		ev := receive(t, w)
+		assert.Equal(t, watch.Bookmark, ev.Type)
+		if ev.Object.(*metav1.PartialObjectMetadata).ResourceVersion == "11" {
+			break
+		}
+	}
+}
+
+func TestBroadcasterSlowWatcher(t *testing.T) {
+	b := newTestBroadcaster()
+	b.BufferSize = 1
+	slow, err := b.Watch(WatchOptions{ResourceVersion: 10})
+	assert.NoError(t, err)
+	stopped, err := b.Watch(WatchOptions{ResourceVersion: 10})
+	assert.NoError(t, err)
+	stopped.Stop()
+	fast, err := b.Watch(WatchOptions{ResourceVersion: 10})
+	assert.NoError(t, err)
+	defer fast.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for rv := uint64(11); rv <= 20; rv++ {
+			b.Action(Event{Type: watch.Added, Object: object("foo", rv), ResourceVersion: rv})
+			assert.Equal(t, strconv.FormatUint(rv, 10), receive(t, fast).Object.(*metav1.PartialObjectMetadata).ResourceVersion)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(wait.ForeverTestTimeout):
+		t.Fatal("a slow watcher should not block the others")
+	}
+
+	// the slow watcher receives the buffered events and is closed
+	count := 0
+	for range slow.ResultChan() {
+		count++
+	}
+	assert.Less(t, count, 10)
+}
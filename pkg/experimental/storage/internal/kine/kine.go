@@ -0,0 +1,227 @@
+// Package kine serves the etcd API in front of SQL databases with kine, for the experimental storages.
+package kine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/k3s-io/kine/pkg/endpoint"
+	"go.etcd.io/etcd/server/v3/embed"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/apiserver/pkg/registry/generic"
+	genericregistry "k8s.io/apiserver/pkg/registry/generic/registry"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/apiserver/pkg/storage/storagebackend"
+	"k8s.io/apiserver/pkg/storage/storagebackend/factory"
+	"k8s.io/apiserver/pkg/storage/value"
+	"k8s.io/apiserver/pkg/util/flowcontrol/request"
+	"k8s.io/client-go/tools/cache"
+)
+
+// defaultNotifyInterval is the interval of the watch progress notifications, the default of the kine command.
+const defaultNotifyInterval = 5 * time.Second
+
+var (
+	endpointsLock sync.Mutex
+	// endpoints are the running endpoints by DSN.
+	endpoints = map[string]*runningEndpoint{}
+)
+
+// runningEndpoint is a kine endpoint shared by all the resources stored in its database, stopped when the last of
+// them is destroyed.
+type runningEndpoint struct {
+	config endpoint.Config
+	etcd   endpoint.ETCDConfig
+	refs   int
+	stopFn func()
+}
+
+// errConfigMismatch is returned when an endpoint is already running for a DSN with other settings.
+var errConfigMismatch = errors.New("a kine endpoint is already running for the database with another configuration")
+
+// acquire starts the endpoint for the DSN of config if it isn't running yet, and returns the configuration to
+// connect to it.  The release function must be called once the endpoint isn't used anymore.  The endpoint already
+// running for a DSN is shared only if it was started with the same settings.
+func acquire(config endpoint.Config) (endpoint.ETCDConfig, func(), error) {
+	endpointsLock.Lock()
+	defer endpointsLock.Unlock()
+
+	e, ok := endpoints[config.Endpoint]
+	if ok && !reflect.DeepEqual(e.config, config) {
+		return endpoint.ETCDConfig{}, nil, errConfigMismatch
+	}
+	if !ok {
+		var err error
+		e, err = listen(config)
+		if err != nil {
+			return endpoint.ETCDConfig{}, nil, err
+		}
+		endpoints[config.Endpoint] = e
+	}
+	e.refs++
+
+	var once sync.Once
+	return e.etcd, func() {
+		once.Do(func() {
+			release(config.Endpoint, e)
+		})
+	}, nil
+}
+
+func release(dsn string, e *runningEndpoint) {
+	endpointsLock.Lock()
+	defer endpointsLock.Unlock()
+
+	e.refs--
+	if e.refs > 0 {
+		return
+	}
+	delete(endpoints, dsn)
+	e.stopFn()
+}
+
+// listen starts kine with a gRPC server of its own, as kine doesn't stop the server it creates.  The server has the
+// keepalive settings of the server kine creates.
+func listen(config endpoint.Config) (*runningEndpoint, error) {
+	e := &runningEndpoint{config: config}
+	if config.NotifyInterval == 0 {
+		config.NotifyInterval = defaultNotifyInterval
+	}
+	opts := []grpc.ServerOption{
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             embed.DefaultGRPCKeepAliveMinTime,
+			PermitWithoutStream: false,
+		}),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    embed.DefaultGRPCKeepAliveInterval,
+			Timeout: embed.DefaultGRPCKeepAliveTimeout,
+		}),
+	}
+	if config.ServerTLSConfig.CertFile != "" && config.ServerTLSConfig.KeyFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(config.ServerTLSConfig.CertFile, config.ServerTLSConfig.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	config.GRPCServer = grpc.NewServer(opts...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	etcdConfig, err := endpoint.Listen(ctx, config)
+	if err != nil {
+		config.GRPCServer.Stop()
+		cancel()
+		return nil, err
+	}
+	e.etcd = etcdConfig
+	e.stopFn = func() {
+		config.GRPCServer.Stop()
+		cancel()
+	}
+	return e, nil
+}
+
+// NewRESTOptionsGetter returns a RESTOptionsGetter storing resources through the kine endpoint of config, encoded
+// with the versions of groupVersioner.  The endpoint is started with the storage of the first resource, and stopped
+// once the storages of all the resources are destroyed, when the server shuts down.  The resources stored in the same
+// database must have the same config, the storages of the others fail to be created.
+//
+// The resources are encrypted at rest like in the etcd of the server, with the transformers of the RESTOptions of
+// server -- the RESTOptionsGetter of the store -- if not nil.
+func NewRESTOptionsGetter(
+	scheme *runtime.Scheme,
+	config endpoint.Config,
+	groupVersioner runtime.GroupVersioner,
+	server generic.RESTOptionsGetter,
+) generic.RESTOptionsGetter {
+	return &restOptionsGetter{
+		scheme:         scheme,
+		config:         config,
+		groupVersioner: groupVersioner,
+		server:         server,
+	}
+}
+
+type restOptionsGetter struct {
+	scheme         *runtime.Scheme
+	config         endpoint.Config
+	groupVersioner runtime.GroupVersioner
+	server         generic.RESTOptionsGetter
+}
+
+// GetRESTOptions implements RESTOptionsGetter interface.
+func (g *restOptionsGetter) GetRESTOptions(resource schema.GroupResource, example runtime.Object) (generic.RESTOptions, error) {
+	s := json.NewSerializer(json.DefaultMetaFactory, g.scheme, g.scheme, false)
+	codec := serializer.NewCodecFactory(g.scheme).
+		CodecForVersions(s, s, g.groupVersioner, g.groupVersioner)
+	var transformer value.Transformer
+	if g.server != nil {
+		serverOptions, err := g.server.GetRESTOptions(resource, example)
+		if err != nil {
+			return generic.RESTOptions{}, err
+		}
+		if serverOptions.StorageConfig != nil {
+			transformer = serverOptions.StorageConfig.Transformer
+		}
+	}
+	restOptions := generic.RESTOptions{
+		ResourcePrefix:            resource.String(),
+		Decorator:                 g.storageWithEndpoint,
+		EnableGarbageCollection:   true,
+		DeleteCollectionWorkers:   1,
+		CountMetricPollPeriod:     time.Minute,
+		StorageObjectCountTracker: request.NewStorageObjectCountTracker(),
+		StorageConfig: &storagebackend.ConfigForResource{
+			GroupResource: resource,
+			Config: storagebackend.Config{
+				Prefix:      "/kine/",
+				Codec:       codec,
+				Transformer: transformer,
+			},
+		},
+	}
+	return restOptions, nil
+}
+
+// storageWithEndpoint creates a cached storage connected to the endpoint, which is released when the storage is
+// destroyed.
+func (g *restOptionsGetter) storageWithEndpoint(
+	config *storagebackend.ConfigForResource,
+	resourcePrefix string,
+	keyFunc func(obj runtime.Object) (string, error),
+	newFunc func() runtime.Object,
+	newListFunc func() runtime.Object,
+	getAttrsFunc storage.AttrFunc,
+	triggerFuncs storage.IndexerFuncs,
+	indexers *cache.Indexers,
+) (storage.Interface, factory.DestroyFunc, error) {
+	etcdConfig, release, err := acquire(g.config)
+	if err != nil {
+		return nil, nil, err
+	}
+	config.Transport = storagebackend.TransportConfig{
+		ServerList:    etcdConfig.Endpoints,
+		TrustedCAFile: etcdConfig.TLSConfig.CAFile,
+		CertFile:      etcdConfig.TLSConfig.CertFile,
+		KeyFile:       etcdConfig.TLSConfig.KeyFile,
+	}
+	s, destroyFunc, err := genericregistry.StorageWithCacher()(
+		config, resourcePrefix, keyFunc, newFunc, newListFunc, getAttrsFunc, triggerFuncs, indexers)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return s, func() {
+		destroyFunc()
+		release()
+	}, nil
+}
@@ -0,0 +1,51 @@
+package kine
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/k3s-io/kine/pkg/endpoint"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquireSharesEndpoints(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	config := endpoint.Config{
+		Endpoint: fmt.Sprintf("sqlite://%s?_journal=WAL&cache=shared&_busy_timeout=30000", path),
+		Listener: "unix://" + path + ".sock",
+	}
+
+	first, releaseFirst, err := acquire(config)
+	if !assert.NoError(t, err) {
+		return
+	}
+	second, releaseSecond, err := acquire(config)
+	if !assert.NoError(t, err) {
+		releaseFirst()
+		return
+	}
+	assert.Equal(t, first, second)
+
+	releaseFirst()
+	releaseFirst()
+	assert.Contains(t, endpoints, config.Endpoint, "expected the endpoint running while used")
+
+	releaseSecond()
+	assert.NotContains(t, endpoints, config.Endpoint, "expected the endpoint stopped")
+
+	// started again on demand
+	_, release, err := acquire(config)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer release()
+
+	t.Run("endpoints should not be shared with other settings", func(t *testing.T) {
+		other := config
+		other.NotifyInterval = time.Minute
+		_, _, err := acquire(other)
+		assert.ErrorIs(t, err, errConfigMismatch)
+	})
+}
@@ -0,0 +1,102 @@
+package storageutil
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/storage"
+
+	builderrest "sigs.k8s.io/apiserver-runtime/pkg/builder/rest"
+)
+
+// Predicate returns the selection predicate for the label and field selectors of the list options.
+func Predicate(options *metainternalversion.ListOptions) storage.SelectionPredicate {
+	p := storage.SelectionPredicate{
+		Label:    labels.Everything(),
+		Field:    fields.Everything(),
+		GetAttrs: builderrest.GetAttrs,
+	}
+	if options != nil {
+		if options.LabelSelector != nil {
+			p.Label = options.LabelSelector
+		}
+		if options.FieldSelector != nil {
+			p.Field = options.FieldSelector
+		}
+	}
+	return p
+}
+
+// Item is an object of a list, with the key it is sorted by.
+type Item struct {
+	Key    string
+	Object runtime.Object
+}
+
+// ObjectKey returns the "/namespace/name" key of an object, or "/name" if it isn't namespaced.
+func ObjectKey(obj runtime.Object) (string, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return "", err
+	}
+	return "/" + path.Join(accessor.GetNamespace(), accessor.GetName()), nil
+}
+
+// StartKey returns the key a list starts from, decoded from the continue token of the options.
+func StartKey(options *metainternalversion.ListOptions) (string, error) {
+	if options == nil || options.Continue == "" {
+		return "", nil
+	}
+	if options.ResourceVersion != "" && options.ResourceVersion != "0" {
+		return "", apierrors.NewBadRequest("specifying resource version is not allowed when using continue")
+	}
+	startKey, _, err := storage.DecodeContinue(options.Continue, "/")
+	if err != nil {
+		return "", apierrors.NewBadRequest(fmt.Sprintf("invalid continue token: %v", err))
+	}
+	return startKey, nil
+}
+
+// SetList sets the page of items starting from startKey into list, along with the continue token of the next page.
+// Pages are ordered by namespace and name, the continue token holds the key to start the next page from.
+// resourceVersion is the resource version of the list.
+func SetList(list runtime.Object, items []Item, startKey string, limit int64, resourceVersion uint64) error {
+	listAccessor, err := meta.ListAccessor(list)
+	if err != nil {
+		return err
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Key < items[j].Key
+	})
+	items = items[sort.Search(len(items), func(i int) bool {
+		return items[i].Key >= startKey
+	}):]
+	if limit > 0 && int64(len(items)) > limit {
+		remaining := int64(len(items)) - limit
+		items = items[:limit]
+		next, err := storage.EncodeContinue(items[len(items)-1].Key+"\x00", "/", int64(max(resourceVersion, 1)))
+		if err != nil {
+			return err
+		}
+		listAccessor.SetContinue(next)
+		listAccessor.SetRemainingItemCount(&remaining)
+	}
+
+	objs := make([]runtime.Object, 0, len(items))
+	for _, item := range items {
+		objs = append(objs, item.Object)
+	}
+	if err := meta.SetList(list, objs); err != nil {
+		return err
+	}
+	listAccessor.SetResourceVersion(strconv.FormatUint(resourceVersion, 10))
+	return nil
+}
@@ -0,0 +1,153 @@
+// Package storageutil provides the object metadata, listing and watch logic shared by the experimental storages.
+package storageutil
+
+import (
+	"context"
+	"fmt"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/generic/registry"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/storage/names"
+)
+
+// BeforeCreate initializes the metadata of an object about to be created, generating its name from generateName
+// if needed.
+func BeforeCreate(ctx context.Context, obj runtime.Object, isNamespaced bool) (metav1.Object, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	if err := EnsureNamespace(ctx, accessor, isNamespaced); err != nil {
+		return nil, err
+	}
+	if accessor.GetResourceVersion() != "" {
+		return nil, apierrors.NewBadRequest("resourceVersion should not be set on objects to be created")
+	}
+	if accessor.GetName() == "" && accessor.GetGenerateName() != "" {
+		accessor.SetName(names.SimpleNameGenerator.GenerateName(accessor.GetGenerateName()))
+	}
+	if accessor.GetName() == "" {
+		return nil, apierrors.NewBadRequest("name or generateName is required")
+	}
+	InitObjectMeta(accessor)
+	return accessor, nil
+}
+
+// EnsureNamespace defaults the namespace of the object to the namespace of the request, and rejects objects from
+// another namespace.
+func EnsureNamespace(ctx context.Context, accessor metav1.Object, isNamespaced bool) error {
+	ns, _ := genericapirequest.NamespaceFrom(ctx)
+	return rest.EnsureObjectNamespaceMatchesRequestNamespace(rest.ExpectedNamespaceForScope(ns, isNamespaced), accessor)
+}
+
+// InitObjectMeta sets the metadata fields owned by the storage on a new object.
+func InitObjectMeta(accessor metav1.Object) {
+	rest.FillObjectMetaSystemFields(accessor)
+	accessor.SetGeneration(1)
+	accessor.SetDeletionTimestamp(nil)
+	accessor.SetDeletionGracePeriodSeconds(nil)
+}
+
+// BeforeUpdate checks the resourceVersion precondition of an update and carries over the metadata fields owned
+// by the storage from the old object.  The generation is incremented if anything but the metadata and the status
+// changed.
+func BeforeUpdate(ctx context.Context, gr schema.GroupResource, obj, oldObj runtime.Object, isNamespaced bool) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	if err := EnsureNamespace(ctx, accessor, isNamespaced); err != nil {
+		return err
+	}
+	oldAccessor, err := meta.Accessor(oldObj)
+	if err != nil {
+		return err
+	}
+	if rv := accessor.GetResourceVersion(); rv != "" && rv != oldAccessor.GetResourceVersion() {
+		return apierrors.NewConflict(gr, oldAccessor.GetName(), fmt.Errorf(registry.OptimisticLockErrorMsg))
+	}
+	return CarryOverObjectMeta(obj, oldObj)
+}
+
+// CarryOverObjectMeta copies the metadata fields owned by the storage from the old object, incrementing the
+// generation if anything but the metadata and the status changed.
+func CarryOverObjectMeta(obj, oldObj runtime.Object) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	oldAccessor, err := meta.Accessor(oldObj)
+	if err != nil {
+		return err
+	}
+	accessor.SetUID(oldAccessor.GetUID())
+	accessor.SetCreationTimestamp(oldAccessor.GetCreationTimestamp())
+	accessor.SetGeneration(oldAccessor.GetGeneration())
+	changed, err := SpecChanged(obj, oldObj)
+	if err != nil {
+		return err
+	}
+	if changed {
+		accessor.SetGeneration(oldAccessor.GetGeneration() + 1)
+	}
+	return nil
+}
+
+// CopyResourceVersion sets the resource version of obj to the one of oldObj.
+func CopyResourceVersion(obj, oldObj runtime.Object) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	oldAccessor, err := meta.Accessor(oldObj)
+	if err != nil {
+		return err
+	}
+	accessor.SetResourceVersion(oldAccessor.GetResourceVersion())
+	return nil
+}
+
+// CheckDeletePreconditions verifies the uid and resourceVersion preconditions of a delete.
+func CheckDeletePreconditions(gr schema.GroupResource, obj runtime.Object, options *metav1.DeleteOptions) error {
+	if options == nil || options.Preconditions == nil {
+		return nil
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	if uid := options.Preconditions.UID; uid != nil && *uid != accessor.GetUID() {
+		return apierrors.NewConflict(gr, accessor.GetName(), fmt.Errorf(
+			"Precondition failed: UID in precondition: %v, UID in object meta: %v", *uid, accessor.GetUID()))
+	}
+	if rv := options.Preconditions.ResourceVersion; rv != nil && *rv != accessor.GetResourceVersion() {
+		return apierrors.NewConflict(gr, accessor.GetName(), fmt.Errorf(
+			"Precondition failed: ResourceVersion in precondition: %v, ResourceVersion in object meta: %v",
+			*rv, accessor.GetResourceVersion()))
+	}
+	return nil
+}
+
+// SpecChanged returns true if anything but the type, metadata and status of the objects differs.
+func SpecChanged(obj, oldObj runtime.Object) (bool, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return false, err
+	}
+	oldContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(oldObj)
+	if err != nil {
+		return false, err
+	}
+	for _, field := range []string{"apiVersion", "kind", "metadata", "status"} {
+		delete(content, field)
+		delete(oldContent, field)
+	}
+	return !apiequality.Semantic.DeepEqual(content, oldContent), nil
+}
@@ -0,0 +1,71 @@
+// Package storagetest provides the resource used in the tests of the experimental storages.
+package storagetest
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource"
+)
+
+// GroupVersion is the group version of Object.
+var GroupVersion = schema.GroupVersion{Group: "test.k8s.io", Version: "v1"}
+
+var _ resource.Object = &Object{}
+
+// Object is a namespaced resource, "testobjects.test.k8s.io".
+type Object struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   string `json:"spec,omitempty"`
+	Status string `json:"status,omitempty"`
+}
+
+func (t *Object) DeepCopyObject() runtime.Object {
+	out := *t
+	t.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	return &out
+}
+
+func (t *Object) GetObjectMeta() *metav1.ObjectMeta {
+	return &t.ObjectMeta
+}
+
+func (t *Object) NamespaceScoped() bool {
+	return true
+}
+
+func (t *Object) New() runtime.Object {
+	return &Object{}
+}
+
+func (t *Object) NewList() runtime.Object {
+	return &ObjectList{}
+}
+
+func (t *Object) GetGroupVersionResource() schema.GroupVersionResource {
+	return GroupVersion.WithResource("testobjects")
+}
+
+func (t *Object) IsStorageVersion() bool {
+	return true
+}
+
+// ObjectList is a list of Objects.
+type ObjectList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []Object `json:"items"`
+}
+
+func (t *ObjectList) DeepCopyObject() runtime.Object {
+	out := *t
+	out.Items = make([]Object, len(t.Items))
+	for i := range t.Items {
+		out.Items[i] = *t.Items[i].DeepCopyObject().(*Object)
+	}
+	return &out
+}
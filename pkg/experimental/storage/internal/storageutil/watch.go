@@ -0,0 +1,86 @@
+package storageutil
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	"k8s.io/apimachinery/pkg/runtime"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/storage/internal/broadcaster"
+)
+
+// ListFunc lists the objects of a storage, see rest.Lister.
+type ListFunc func(ctx context.Context, options *metainternalversion.ListOptions) (runtime.Object, error)
+
+// WatchOptions returns the broadcaster options of a watch request.  The initial objects are listed with list, and
+// resourceVersion is the current resource version of the storage.
+func WatchOptions(
+	ctx context.Context,
+	options *metainternalversion.ListOptions,
+	isNamespaced bool,
+	resourceVersion uint64,
+	list ListFunc,
+) (broadcaster.WatchOptions, error) {
+	if options == nil {
+		options = &metainternalversion.ListOptions{}
+	}
+	p := Predicate(options)
+	namespace := ""
+	if isNamespaced {
+		namespace, _ = genericapirequest.NamespaceFrom(ctx)
+	}
+	watchOptions := broadcaster.WatchOptions{
+		Filter: func(obj runtime.Object) bool {
+			if namespace != "" {
+				accessor, err := meta.Accessor(obj)
+				if err != nil || accessor.GetNamespace() != namespace {
+					return false
+				}
+			}
+			ok, err := p.Matches(obj)
+			return err == nil && ok
+		},
+		Bookmarks: options.AllowWatchBookmarks,
+	}
+
+	// without a resource version, the watch starts with the existing objects
+	sendInitialEvents := options.ResourceVersion == "" || options.ResourceVersion == "0"
+	if options.SendInitialEvents != nil {
+		sendInitialEvents = *options.SendInitialEvents
+		watchOptions.InitialEventsEnd = sendInitialEvents
+	}
+	switch {
+	case sendInitialEvents:
+		l, err := list(ctx, &metainternalversion.ListOptions{
+			LabelSelector: options.LabelSelector,
+			FieldSelector: options.FieldSelector,
+		})
+		if err != nil {
+			return watchOptions, err
+		}
+		if watchOptions.InitialObjects, err = meta.ExtractList(l); err != nil {
+			return watchOptions, err
+		}
+		listAccessor, err := meta.ListAccessor(l)
+		if err != nil {
+			return watchOptions, err
+		}
+		if watchOptions.ResourceVersion, err = strconv.ParseUint(listAccessor.GetResourceVersion(), 10, 64); err != nil {
+			return watchOptions, err
+		}
+	case options.ResourceVersion == "" || options.ResourceVersion == "0":
+		watchOptions.ResourceVersion = resourceVersion
+	default:
+		rv, err := strconv.ParseUint(options.ResourceVersion, 10, 64)
+		if err != nil {
+			return watchOptions, apierrors.NewBadRequest(fmt.Sprintf("invalid resource version %q", options.ResourceVersion))
+		}
+		watchOptions.ResourceVersion = rv
+	}
+	return watchOptions, nil
+}
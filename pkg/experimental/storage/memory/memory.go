@@ -0,0 +1,32 @@
+// Package memory provides a storage keeping objects in memory, for tests and ephemeral resources.
+package memory
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/registry/generic"
+	"k8s.io/apiserver/pkg/registry/rest"
+
+	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource"
+	builderrest "sigs.k8s.io/apiserver-runtime/pkg/builder/rest"
+)
+
+// NewMemoryStorageProvider keeps the objects in memory, they are lost when the server stops.  The storage supports
+// resource versions, label and field selectors, pagination and watches resuming from a resource version, like the
+// filepath storage.
+//
+// An example of storing example resource in memory will be:
+//
+//	builder.APIServer.
+//	  WithResourceAndHandler(&v1alpha1.ExampleResource{},
+//	        memory.NewMemoryStorageProvider(&v1alpha1.ExampleResource{})).
+//	  Build()
+func NewMemoryStorageProvider(obj resource.Object) builderrest.ResourceHandlerProvider {
+	return func(scheme *runtime.Scheme, getter generic.RESTOptionsGetter) (rest.Storage, error) {
+		return NewMemoryREST(
+			obj.GetGroupVersionResource().GroupResource(),
+			obj.NamespaceScoped(),
+			obj.New,
+			obj.NewList,
+		), nil
+	}
+}
@@ -0,0 +1,413 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/util/dryrun"
+
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/storage/internal/broadcaster"
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/storage/internal/storageutil"
+)
+
+var _ rest.StandardStorage = &memoryREST{}
+var _ rest.Scoper = &memoryREST{}
+var _ rest.Storage = &memoryREST{}
+
+// NewMemoryREST instantiates a new REST storage keeping the objects in memory.
+func NewMemoryREST(
+	groupResource schema.GroupResource,
+	isNamespaced bool,
+	newFunc func() runtime.Object,
+	newListFunc func() runtime.Object,
+) rest.Storage {
+	return &memoryREST{
+		TableConvertor: rest.NewDefaultTableConvertor(groupResource),
+		groupResource:  groupResource,
+		isNamespaced:   isNamespaced,
+		objects:        map[string]runtime.Object{},
+		broadcaster:    broadcaster.New(0, newFunc),
+		newFunc:        newFunc,
+		newListFunc:    newListFunc,
+	}
+}
+
+type memoryREST struct {
+	rest.TableConvertor
+	groupResource schema.GroupResource
+	isNamespaced  bool
+
+	// mu guards the objects, which are stored by key and never modified once stored, and resourceVersion, the
+	// resource version of the last write
+	mu              sync.RWMutex
+	objects         map[string]runtime.Object
+	resourceVersion uint64
+	broadcaster     *broadcaster.Broadcaster
+
+	newFunc     func() runtime.Object
+	newListFunc func() runtime.Object
+}
+
+func (m *memoryREST) New() runtime.Object {
+	return m.newFunc()
+}
+
+func (m *memoryREST) Destroy() {
+	m.broadcaster.Shutdown()
+}
+
+func (m *memoryREST) NewList() runtime.Object {
+	return m.newListFunc()
+}
+
+func (m *memoryREST) NamespaceScoped() bool {
+	return m.isNamespaced
+}
+
+func (m *memoryREST) Get(
+	ctx context.Context,
+	name string,
+	options *metav1.GetOptions,
+) (runtime.Object, error) {
+	key, err := m.objectKey(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	obj, ok := m.objects[key]
+	if !ok {
+		return nil, apierrors.NewNotFound(m.groupResource, name)
+	}
+	return obj.DeepCopyObject(), nil
+}
+
+func (m *memoryREST) List(
+	ctx context.Context,
+	options *metainternalversion.ListOptions,
+) (runtime.Object, error) {
+	startKey, err := storageutil.StartKey(options)
+	if err != nil {
+		return nil, err
+	}
+	prefix := "/"
+	if ns, _ := genericapirequest.NamespaceFrom(ctx); m.isNamespaced && ns != "" {
+		prefix = "/" + ns + "/"
+	}
+
+	p := storageutil.Predicate(options)
+	var items []storageutil.Item
+	m.mu.RLock()
+	rv := m.resourceVersion
+	for key, obj := range m.objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if ok, err := p.Matches(obj); err != nil || !ok {
+			if err != nil {
+				m.mu.RUnlock()
+				return nil, err
+			}
+			continue
+		}
+		items = append(items, storageutil.Item{Key: key, Object: obj.DeepCopyObject()})
+	}
+	m.mu.RUnlock()
+
+	newListObj := m.NewList()
+	limit := int64(0)
+	if options != nil {
+		limit = options.Limit
+	}
+	if err := storageutil.SetList(newListObj, items, startKey, limit, rv); err != nil {
+		return nil, err
+	}
+	return newListObj, nil
+}
+
+func (m *memoryREST) Create(
+	ctx context.Context,
+	obj runtime.Object,
+	createValidation rest.ValidateObjectFunc,
+	options *metav1.CreateOptions,
+) (runtime.Object, error) {
+	accessor, err := storageutil.BeforeCreate(ctx, obj, m.isNamespaced)
+	if err != nil {
+		return nil, err
+	}
+	if createValidation != nil {
+		if err := createValidation(ctx, obj); err != nil {
+			return nil, err
+		}
+	}
+	key, err := m.objectKey(ctx, accessor.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.objects[key]; ok {
+		if accessor.GetGenerateName() != "" {
+			return nil, apierrors.NewGenerateNameConflict(m.groupResource, accessor.GetName(), 1)
+		}
+		return nil, apierrors.NewAlreadyExists(m.groupResource, accessor.GetName())
+	}
+	if options != nil && dryrun.IsDryRun(options.DryRun) {
+		return obj, nil
+	}
+	if err := m.commitLocked(watch.Added, key, obj, nil); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// Update reads the object, computes and validates the updated object without holding the lock, so that admission
+// may call the storage, and retries if the object changed in the meantime.
+func (m *memoryREST) Update(
+	ctx context.Context,
+	name string,
+	objInfo rest.UpdatedObjectInfo,
+	createValidation rest.ValidateObjectFunc,
+	updateValidation rest.ValidateObjectUpdateFunc,
+	forceAllowCreate bool,
+	options *metav1.UpdateOptions,
+) (runtime.Object, bool, error) {
+	key, err := m.objectKey(ctx, name)
+	if err != nil {
+		return nil, false, err
+	}
+	dryRun := options != nil && dryrun.IsDryRun(options.DryRun)
+	for {
+		oldObj, err := m.Get(ctx, name, nil)
+		isCreate := apierrors.IsNotFound(err)
+		if err != nil && (!isCreate || !forceAllowCreate) {
+			return nil, false, err
+		}
+		updatedObj, err := objInfo.UpdatedObject(ctx, oldObj)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if isCreate {
+			accessor, err := meta.Accessor(updatedObj)
+			if err != nil {
+				return nil, false, err
+			}
+			if err := storageutil.EnsureNamespace(ctx, accessor, m.isNamespaced); err != nil {
+				return nil, false, err
+			}
+			storageutil.InitObjectMeta(accessor)
+			if createValidation != nil {
+				if err := createValidation(ctx, updatedObj); err != nil {
+					return nil, false, err
+				}
+			}
+			accessor.SetResourceVersion("")
+		} else {
+			if err := storageutil.BeforeUpdate(ctx, m.groupResource, updatedObj, oldObj, m.isNamespaced); err != nil {
+				return nil, false, err
+			}
+			if updateValidation != nil {
+				if err := updateValidation(ctx, updatedObj, oldObj); err != nil {
+					return nil, false, err
+				}
+			}
+			// the object keeps the resource version it is stored with until it is committed
+			if err := storageutil.CopyResourceVersion(updatedObj, oldObj); err != nil {
+				return nil, false, err
+			}
+		}
+
+		eventType := watch.Modified
+		if isCreate {
+			eventType = watch.Added
+		}
+		committed, err := m.commitIfUnchanged(eventType, key, updatedObj, oldObj, dryRun)
+		if err != nil {
+			return nil, false, err
+		}
+		if committed {
+			return updatedObj, isCreate, nil
+		}
+	}
+}
+
+func (m *memoryREST) Delete(
+	ctx context.Context,
+	name string,
+	deleteValidation rest.ValidateObjectFunc,
+	options *metav1.DeleteOptions) (runtime.Object, bool, error) {
+	key, err := m.objectKey(ctx, name)
+	if err != nil {
+		return nil, false, err
+	}
+	dryRun := options != nil && dryrun.IsDryRun(options.DryRun)
+	for {
+		oldObj, err := m.Get(ctx, name, nil)
+		if err != nil {
+			return nil, false, err
+		}
+		if err := storageutil.CheckDeletePreconditions(m.groupResource, oldObj, options); err != nil {
+			return nil, false, err
+		}
+		if deleteValidation != nil {
+			if err := deleteValidation(ctx, oldObj); err != nil {
+				return nil, false, err
+			}
+		}
+
+		// the deleted object carries the resource version of the deletion
+		deletedObj := oldObj.DeepCopyObject()
+		committed, err := m.commitIfUnchanged(watch.Deleted, key, deletedObj, oldObj, dryRun)
+		if err != nil {
+			return nil, false, err
+		}
+		if committed {
+			return deletedObj, true, nil
+		}
+	}
+}
+
+func (m *memoryREST) DeleteCollection(
+	ctx context.Context,
+	deleteValidation rest.ValidateObjectFunc,
+	options *metav1.DeleteOptions,
+	listOptions *metainternalversion.ListOptions,
+) (runtime.Object, error) {
+	list, err := m.List(ctx, listOptions)
+	if err != nil {
+		return nil, err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []runtime.Object
+	for _, item := range items {
+		accessor, err := meta.Accessor(item)
+		if err != nil {
+			return nil, err
+		}
+		itemCtx := ctx
+		if accessor.GetNamespace() != "" {
+			itemCtx = genericapirequest.WithNamespace(ctx, accessor.GetNamespace())
+		}
+		obj, _, err := m.Delete(itemCtx, accessor.GetName(), deleteValidation, options)
+		if apierrors.IsNotFound(err) {
+			// deleted concurrently
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		deleted = append(deleted, obj)
+	}
+	newListObj := m.NewList()
+	if err := meta.SetList(newListObj, deleted); err != nil {
+		return nil, err
+	}
+	return newListObj, nil
+}
+
+func (m *memoryREST) Watch(ctx context.Context, options *metainternalversion.ListOptions) (watch.Interface, error) {
+	m.mu.RLock()
+	rv := m.resourceVersion
+	m.mu.RUnlock()
+	watchOptions, err := storageutil.WatchOptions(ctx, options, m.isNamespaced, rv, m.List)
+	if err != nil {
+		return nil, err
+	}
+	return m.broadcaster.Watch(watchOptions)
+}
+
+// objectKey returns the "/namespace/name" key of an object, or "/name" if it isn't namespaced.
+func (m *memoryREST) objectKey(ctx context.Context, name string) (string, error) {
+	if name == "" || strings.Contains(name, "/") {
+		return "", apierrors.NewBadRequest(fmt.Sprintf("invalid name %q", name))
+	}
+	if m.isNamespaced {
+		ns, _ := genericapirequest.NamespaceFrom(ctx)
+		if ns == "" {
+			return "", apierrors.NewBadRequest("namespace is required")
+		}
+		return "/" + ns + "/" + name, nil
+	}
+	return "/" + name, nil
+}
+
+// commitIfUnchanged commits obj unless the object stored under key isn't oldObj anymore, oldObj being nil if no
+// object was stored.  Nothing is changed by dry-run requests once the stored object has been checked.  It returns
+// false if the stored object changed.
+func (m *memoryREST) commitIfUnchanged(
+	eventType watch.EventType,
+	key string,
+	obj, oldObj runtime.Object,
+	dryRun bool,
+) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, ok := m.objects[key]
+	if ok != (oldObj != nil) {
+		return false, nil
+	}
+	if ok {
+		currentAccessor, err := meta.Accessor(current)
+		if err != nil {
+			return false, err
+		}
+		oldAccessor, err := meta.Accessor(oldObj)
+		if err != nil {
+			return false, err
+		}
+		if currentAccessor.GetResourceVersion() != oldAccessor.GetResourceVersion() {
+			return false, nil
+		}
+	}
+	if dryRun {
+		return true, nil
+	}
+	if eventType != watch.Modified {
+		current = nil
+	}
+	return true, m.commitLocked(eventType, key, obj, current)
+}
+
+// commitLocked assigns the next resource version to obj and stores it under key, or removes the object stored
+// under key for watch.Deleted events, obj being the last state of the object.  The change is recorded for the
+// watchers, oldObj being the previous state of the object for watch.Modified events.
+func (m *memoryREST) commitLocked(eventType watch.EventType, key string, obj, oldObj runtime.Object) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	rv := m.resourceVersion + 1
+	accessor.SetResourceVersion(strconv.FormatUint(rv, 10))
+	stored := obj.DeepCopyObject()
+	if eventType == watch.Deleted {
+		delete(m.objects, key)
+	} else {
+		m.objects[key] = stored
+	}
+	m.resourceVersion = rv
+	m.broadcaster.Action(broadcaster.Event{
+		Type:            eventType,
+		Object:          stored,
+		OldObject:       oldObj,
+		ResourceVersion: rv,
+	})
+	return nil
+}
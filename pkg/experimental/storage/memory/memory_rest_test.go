@@ -0,0 +1,191 @@
+package memory
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/storage/internal/storageutil/storagetest"
+)
+
+func newTestREST() *memoryREST {
+	storage, _ := NewMemoryStorageProvider(&storagetest.Object{})(runtime.NewScheme(), nil)
+	return storage.(*memoryREST)
+}
+
+func testContext() context.Context {
+	return genericapirequest.WithNamespace(context.TODO(), "default")
+}
+
+func TestMemoryREST(t *testing.T) {
+	m := newTestREST()
+	defer m.Destroy()
+	ctx := testContext()
+
+	created, err := m.Create(ctx, &storagetest.Object{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "foo-"},
+		Spec:       "a",
+	}, nil, &metav1.CreateOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	obj := created.(*storagetest.Object)
+	assert.NotEmpty(t, obj.UID)
+	assert.Equal(t, int64(1), obj.Generation)
+	assert.Equal(t, "1", obj.ResourceVersion)
+
+	t.Run("objects should be copied", func(t *testing.T) {
+		obj.Spec = "modified"
+		got, err := m.Get(ctx, obj.Name, &metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "a", got.(*storagetest.Object).Spec)
+		obj.Spec = "a"
+	})
+	t.Run("updates should check the resource version", func(t *testing.T) {
+		update := obj.DeepCopyObject().(*storagetest.Object)
+		update.Spec = "b"
+		updated, _, err := m.Update(ctx, obj.Name, rest.DefaultUpdatedObjectInfo(update), nil, nil, false, &metav1.UpdateOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "2", updated.(*storagetest.Object).ResourceVersion)
+		assert.Equal(t, int64(2), updated.(*storagetest.Object).Generation)
+		_, _, err = m.Update(ctx, obj.Name, rest.DefaultUpdatedObjectInfo(obj), nil, nil, false, &metav1.UpdateOptions{})
+		assert.True(t, apierrors.IsConflict(err))
+	})
+	t.Run("dry-run requests should not change anything", func(t *testing.T) {
+		dryRun := []string{metav1.DryRunAll}
+		_, err := m.Create(ctx, &storagetest.Object{ObjectMeta: metav1.ObjectMeta{Name: "bar"}}, nil, &metav1.CreateOptions{DryRun: dryRun})
+		assert.NoError(t, err)
+		_, _, err = m.Delete(ctx, obj.Name, nil, &metav1.DeleteOptions{DryRun: dryRun})
+		assert.NoError(t, err)
+		list, err := m.List(ctx, nil)
+		assert.NoError(t, err)
+		assert.Len(t, list.(*storagetest.ObjectList).Items, 1)
+		assert.Equal(t, "2", list.(*storagetest.ObjectList).ResourceVersion)
+	})
+	t.Run("deleted objects should have the resource version of the deletion", func(t *testing.T) {
+		deleted, _, err := m.Delete(ctx, obj.Name, nil, &metav1.DeleteOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "3", deleted.(*storagetest.Object).ResourceVersion)
+		_, err = m.Get(ctx, obj.Name, &metav1.GetOptions{})
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+}
+
+func TestMemoryRESTList(t *testing.T) {
+	m := newTestREST()
+	defer m.Destroy()
+	for i := 0; i < 10; i++ {
+		ns := "default"
+		if i%2 == 1 {
+			ns = "other"
+		}
+		_, err := m.Create(genericapirequest.WithNamespace(context.TODO(), ns), &storagetest.Object{ObjectMeta: metav1.ObjectMeta{
+			Name:   "foo-" + strconv.Itoa(i),
+			Labels: map[string]string{"even": strconv.FormatBool(i%2 == 0)},
+		}}, nil, &metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+
+	list, err := m.List(testContext(), nil)
+	assert.NoError(t, err)
+	assert.Len(t, list.(*storagetest.ObjectList).Items, 5, "only objects of the namespace should be listed")
+
+	list, err = m.List(context.TODO(), &metainternalversion.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{"even": "false"}),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, list.(*storagetest.ObjectList).Items, 5)
+
+	var names []string
+	options := &metainternalversion.ListOptions{Limit: 3}
+	for {
+		list, err := m.List(context.TODO(), options)
+		if !assert.NoError(t, err) {
+			return
+		}
+		for _, item := range list.(*storagetest.ObjectList).Items {
+			names = append(names, item.Namespace+"/"+item.Name)
+		}
+		if list.(*storagetest.ObjectList).Continue == "" {
+			break
+		}
+		options.Continue = list.(*storagetest.ObjectList).Continue
+	}
+	assert.Len(t, names, 10)
+	assert.Equal(t, "default/foo-0", names[0])
+	assert.Equal(t, "other/foo-9", names[9])
+}
+
+func TestMemoryRESTWatch(t *testing.T) {
+	m := newTestREST()
+	defer m.Destroy()
+	ctx := testContext()
+	for i := 0; i < 3; i++ {
+		_, err := m.Create(ctx, &storagetest.Object{ObjectMeta: metav1.ObjectMeta{GenerateName: "foo-"}}, nil, &metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+
+	w, err := m.Watch(ctx, &metainternalversion.ListOptions{ResourceVersion: "1"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer w.Stop()
+	for _, rv := range []string{"2", "3"} {
+		ev := <-w.ResultChan()
+		assert.Equal(t, watch.Added, ev.Type)
+		assert.Equal(t, rv, ev.Object.(*storagetest.Object).ResourceVersion)
+	}
+
+	w2, err := m.Watch(ctx, &metainternalversion.ListOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer w2.Stop()
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, watch.Added, (<-w2.ResultChan()).Type)
+	}
+}
+
+func TestMemoryRESTConcurrentUpdates(t *testing.T) {
+	m := newTestREST()
+	defer m.Destroy()
+	ctx := testContext()
+	_, err := m.Create(ctx, &storagetest.Object{ObjectMeta: metav1.ObjectMeta{Name: "counter"}, Spec: "0"}, nil, &metav1.CreateOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := m.Update(ctx, "counter", rest.DefaultUpdatedObjectInfo(nil,
+				func(_ context.Context, _, old runtime.Object) (runtime.Object, error) {
+					updated := old.DeepCopyObject().(*storagetest.Object)
+					n, _ := strconv.Atoi(updated.Spec)
+					updated.Spec = strconv.Itoa(n + 1)
+					// unconditional update
+					updated.ResourceVersion = ""
+					return updated, nil
+				}), nil, nil, false, &metav1.UpdateOptions{})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	obj, err := m.Get(ctx, "counter", &metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "20", obj.(*storagetest.Object).Spec, "no update should be lost")
+}
@@ -2,21 +2,15 @@
 package mysql
 
 import (
-	"context"
 	"fmt"
-	"time"
 
 	"github.com/k3s-io/kine/pkg/endpoint"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/runtime/serializer"
-	"k8s.io/apimachinery/pkg/runtime/serializer/json"
 	"k8s.io/apiserver/pkg/registry/generic"
 	genericregistry "k8s.io/apiserver/pkg/registry/generic/registry"
-	"k8s.io/apiserver/pkg/storage/storagebackend"
-	"k8s.io/apiserver/pkg/util/flowcontrol/request"
 
 	builderrest "sigs.k8s.io/apiserver-runtime/pkg/builder/rest"
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/storage/internal/kine"
 )
 
 // NewMysqlStorageProvider replaces underlying persistent layer (which by default is etcd) w/ MySQL.
@@ -30,60 +24,26 @@ import (
 //	        "", // mysql password 		e.g. "password"
 //	        "", // mysql database name 	e.g. "mydb"
 //	        )).Build()
-func NewMysqlStorageProvider(host string, port int32, username, password, database string) builderrest.StoreFn {
+//
+// All the resources stored in the same database share one kine endpoint, listening on a loopback port and stopped
+// when the server shuts down.  The connection pool and TLS are configured with options: the resources stored in the
+// same database must use the same options, the storages of the others fail to be created.
+func NewMysqlStorageProvider(host string, port int32, username, password, database string, opts ...Option) builderrest.StoreFn {
 	dsn := fmt.Sprintf("mysql://%s:%s@tcp(%s:%d)/%s",
 		username,
 		password,
 		host,
 		port,
 		database)
-
-	return func(scheme *runtime.Scheme, s *genericregistry.Store, options *generic.StoreOptions) {
-		options.RESTOptions = &kineProxiedRESTOptionsGetter{
-			scheme:         scheme,
-			dsn:            dsn,
-			groupVersioner: s.StorageVersioner,
-		}
+	config := endpoint.Config{
+		Endpoint: dsn,
+		Listener: "tcp://127.0.0.1:0",
 	}
-}
-
-type kineProxiedRESTOptionsGetter struct {
-	scheme         *runtime.Scheme
-	dsn            string
-	groupVersioner runtime.GroupVersioner
-}
-
-// GetRESTOptions implements RESTOptionsGetter interface.
-func (g *kineProxiedRESTOptionsGetter) GetRESTOptions(resource schema.GroupResource, example runtime.Object) (generic.RESTOptions, error) {
-	etcdConfig, err := endpoint.Listen(context.TODO(), endpoint.Config{
-		Endpoint: g.dsn,
-	})
-	if err != nil {
-		return generic.RESTOptions{}, err
+	for _, opt := range opts {
+		opt(&config)
 	}
-	s := json.NewSerializer(json.DefaultMetaFactory, g.scheme, g.scheme, false)
-	codec := serializer.NewCodecFactory(g.scheme).
-		CodecForVersions(s, s, g.groupVersioner, g.groupVersioner)
-	restOptions := generic.RESTOptions{
-		ResourcePrefix:            resource.String(),
-		Decorator:                 genericregistry.StorageWithCacher(),
-		EnableGarbageCollection:   true,
-		DeleteCollectionWorkers:   1,
-		CountMetricPollPeriod:     time.Minute,
-		StorageObjectCountTracker: request.NewStorageObjectCountTracker(),
-		StorageConfig: &storagebackend.ConfigForResource{
-			GroupResource: resource,
-			Config: storagebackend.Config{
-				Prefix: "/kine/",
-				Codec:  codec,
-				Transport: storagebackend.TransportConfig{
-					ServerList:    etcdConfig.Endpoints,
-					TrustedCAFile: etcdConfig.TLSConfig.CAFile,
-					CertFile:      etcdConfig.TLSConfig.CertFile,
-					KeyFile:       etcdConfig.TLSConfig.KeyFile,
-				},
-			},
-		},
+
+	return func(scheme *runtime.Scheme, s *genericregistry.Store, options *generic.StoreOptions) {
+		options.RESTOptions = kine.NewRESTOptionsGetter(scheme, config, s.StorageVersioner, options.RESTOptions)
 	}
-	return restOptions, nil
 }
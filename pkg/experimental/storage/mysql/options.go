@@ -0,0 +1,48 @@
+package mysql
+
+import (
+	"time"
+
+	"github.com/k3s-io/kine/pkg/drivers/generic"
+	"github.com/k3s-io/kine/pkg/endpoint"
+	"github.com/k3s-io/kine/pkg/tls"
+)
+
+// Option configures the kine endpoint in front of the database.
+type Option func(*endpoint.Config)
+
+// WithConnectionPool limits the connections to the database: maxIdle idle connections, maxOpen open connections in
+// total (unlimited if not positive), each reused for at most maxLifetime (forever if zero).
+func WithConnectionPool(maxIdle, maxOpen int, maxLifetime time.Duration) Option {
+	return func(c *endpoint.Config) {
+		c.ConnectionPoolConfig = generic.ConnectionPoolConfig{
+			MaxIdle:     maxIdle,
+			MaxOpen:     maxOpen,
+			MaxLifetime: maxLifetime,
+		}
+	}
+}
+
+// WithDatabaseTLS connects to the database with TLS, verifying it with the CA file and authenticating with the
+// client certificate and key files, if set.
+func WithDatabaseTLS(caFile, certFile, keyFile string) Option {
+	return func(c *endpoint.Config) {
+		c.BackendTLSConfig = tls.Config{
+			CAFile:   caFile,
+			CertFile: certFile,
+			KeyFile:  keyFile,
+		}
+	}
+}
+
+// WithServerTLS serves the etcd API of kine with TLS, with the certificate and key files.  The storage verifies the
+// certificate with the CA file.
+func WithServerTLS(caFile, certFile, keyFile string) Option {
+	return func(c *endpoint.Config) {
+		c.ServerTLSConfig = tls.Config{
+			CAFile:   caFile,
+			CertFile: certFile,
+			KeyFile:  keyFile,
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Package passthrough provides a storage serving a view of a resource of the host kube-apiserver, e.g. a resource
+// derived from the core ConfigMaps or Services.
+package passthrough
+
+import (
+	"errors"
+	"net/http"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/registry/generic"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/client-go/dynamic"
+	restclient "k8s.io/client-go/rest"
+
+	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource"
+	builderrest "sigs.k8s.io/apiserver-runtime/pkg/builder/rest"
+	"sigs.k8s.io/apiserver-runtime/pkg/util/loopback"
+)
+
+// ToObjectFunc converts an object of the host resource to the object served.  Objects for which it returns nil
+// are not served.
+type ToObjectFunc func(host runtime.Object) (runtime.Object, error)
+
+// FromObjectFunc converts the object served to an object of the host resource, for creates and updates.  The name,
+// namespace and resource version of the host object are set from the object served, and its apiVersion and kind
+// from its Go type if it is a client-go type.
+type FromObjectFunc func(obj runtime.Object) (runtime.Object, error)
+
+// Option configures the storage returned by NewPassthroughStorageProvider.
+type Option func(*passthroughREST)
+
+// WithWrites serves creates and updates, which are forwarded to the host resource after being converted with
+// fromObject.  The storage is read-only by default.
+func WithWrites(fromObject FromObjectFunc) Option {
+	return func(p *passthroughREST) {
+		p.fromObject = fromObject
+	}
+}
+
+// WithClientConfig connects to the kube-apiserver of config, rather than to the one exposed by the builder with
+// ExposeLoopbackMasterClientConfig.
+func WithClientConfig(config *restclient.Config) Option {
+	return func(p *passthroughREST) {
+		p.clientConfig = config
+	}
+}
+
+// WithoutImpersonation forwards the requests with the identity of the client config only, rather than impersonating
+// the user of each request.
+//
+// WARNING: any user allowed to access the resource served can then access the host resource with the permissions
+// of that identity, including the objects it can read or write that the user can't.  Only use it if the resource
+// served is authorized at least as strictly as the host resource, or if the identity has no more permissions than
+// every user of the resource.
+func WithoutImpersonation() Option {
+	return func(p *passthroughREST) {
+		p.withoutImpersonation = true
+	}
+}
+
+// NewPassthroughStorageProvider serves obj as a view of the host resource of the kube-apiserver: gets, lists and
+// watches are forwarded to the host resource, in the same namespace, and the objects returned -- created by
+// newHost -- are converted with toObject.  The label and field selectors apply to the objects converted.
+//
+// The storage connects to the kube-apiserver with the loopback client config of the builder, which must be
+// exposed with ExposeLoopbackMasterClientConfig.  The requests are forwarded impersonating their user -- its name,
+// UID, groups and extra -- so that the kube-apiserver authorizes them as if the user accessed the host resource:
+// the identity of the client config must be allowed to impersonate users, groups, uids and userextras, and
+// requests without a user are rejected.  See WithoutImpersonation to forward them with the identity of the client
+// config instead.
+//
+//	builder.APIServer.
+//	  WithResourceAndHandler(&v1alpha1.ExampleResource{}, passthrough.NewPassthroughStorageProvider(
+//	        &v1alpha1.ExampleResource{},
+//	        corev1.SchemeGroupVersion.WithResource("configmaps"),
+//	        func() runtime.Object { return &corev1.ConfigMap{} },
+//	        func(host runtime.Object) (runtime.Object, error) {
+//	              cm := host.(*corev1.ConfigMap)
+//	              return &v1alpha1.ExampleResource{ObjectMeta: cm.ObjectMeta, Spec: cm.Data["spec"]}, nil
+//	        })).
+//	  ExposeLoopbackMasterClientConfig().
+//	  Build()
+func NewPassthroughStorageProvider(
+	obj resource.Object,
+	host schema.GroupVersionResource,
+	newHost func() runtime.Object,
+	toObject ToObjectFunc,
+	opts ...Option,
+) builderrest.ResourceHandlerProvider {
+	return func(scheme *runtime.Scheme, getter generic.RESTOptionsGetter) (rest.Storage, error) {
+		p := &passthroughREST{
+			TableConvertor: rest.NewDefaultTableConvertor(obj.GetGroupVersionResource().GroupResource()),
+			groupResource:  obj.GetGroupVersionResource().GroupResource(),
+			host:           host,
+			isNamespaced:   obj.NamespaceScoped(),
+			newFunc:        obj.New,
+			newListFunc:    obj.NewList,
+			newHostFunc:    newHost,
+			toObject:       toObject,
+		}
+		for _, opt := range opts {
+			opt(p)
+		}
+		if p.clientConfig == nil {
+			p.clientConfig = loopback.GetLoopbackMasterClientConfig()
+		}
+		if p.clientConfig == nil {
+			return nil, errors.New("no kube-apiserver client config, expose it with ExposeLoopbackMasterClientConfig")
+		}
+		config := p.clientConfig
+		if !p.withoutImpersonation {
+			config = restclient.CopyConfig(config)
+			config.Impersonate = restclient.ImpersonationConfig{}
+			config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+				return &impersonatingRoundTripper{delegate: rt}
+			})
+		}
+		client, err := dynamic.NewForConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		p.client = client.Resource(host)
+		if p.fromObject != nil {
+			return &writablePassthroughREST{p}, nil
+		}
+		return p, nil
+	}
+}
@@ -0,0 +1,436 @@
+package passthrough
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/client-go/dynamic"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+
+	builderrest "sigs.k8s.io/apiserver-runtime/pkg/builder/rest"
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/storage/internal/storageutil"
+)
+
+var _ rest.Getter = &passthroughREST{}
+var _ rest.Lister = &passthroughREST{}
+var _ rest.Watcher = &passthroughREST{}
+var _ rest.Scoper = &passthroughREST{}
+var _ builderrest.ExternalStorage = &passthroughREST{}
+var _ rest.Creater = &writablePassthroughREST{}
+var _ rest.Updater = &writablePassthroughREST{}
+
+type passthroughREST struct {
+	rest.TableConvertor
+	groupResource schema.GroupResource
+	isNamespaced  bool
+
+	// host is the resource of the kube-apiserver served, through client
+	host         schema.GroupVersionResource
+	clientConfig *restclient.Config
+	client       dynamic.NamespaceableResourceInterface
+	// withoutImpersonation forwards the requests with client, rather than impersonating their user
+	withoutImpersonation bool
+
+	toObject   ToObjectFunc
+	fromObject FromObjectFunc
+
+	newFunc     func() runtime.Object
+	newListFunc func() runtime.Object
+	newHostFunc func() runtime.Object
+}
+
+// writablePassthroughREST serves creates and updates in addition, as the verbs served depend on the interfaces
+// implemented by the storage.
+type writablePassthroughREST struct {
+	*passthroughREST
+}
+
+func (p *passthroughREST) New() runtime.Object {
+	return p.newFunc()
+}
+
+func (p *passthroughREST) Destroy() {}
+
+// IsExternalStorage returns true, as the objects are stored by the kube-apiserver.
+func (p *passthroughREST) IsExternalStorage() bool {
+	return true
+}
+
+func (p *passthroughREST) NewList() runtime.Object {
+	return p.newListFunc()
+}
+
+func (p *passthroughREST) NamespaceScoped() bool {
+	return p.isNamespaced
+}
+
+func (p *passthroughREST) Get(
+	ctx context.Context,
+	name string,
+	options *metav1.GetOptions,
+) (runtime.Object, error) {
+	if options == nil {
+		options = &metav1.GetOptions{}
+	}
+	client, err := p.resource(ctx)
+	if err != nil {
+		return nil, err
+	}
+	u, err := client.Get(ctx, name, *options)
+	if err != nil {
+		return nil, p.hostError(err, name)
+	}
+	obj, err := p.convert(u)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, apierrors.NewNotFound(p.groupResource, name)
+	}
+	return obj, nil
+}
+
+func (p *passthroughREST) List(
+	ctx context.Context,
+	options *metainternalversion.ListOptions,
+) (runtime.Object, error) {
+	hostOptions := metav1.ListOptions{}
+	if options != nil {
+		hostOptions.ResourceVersion = options.ResourceVersion
+		hostOptions.ResourceVersionMatch = options.ResourceVersionMatch
+		hostOptions.Limit = options.Limit
+		hostOptions.Continue = options.Continue
+	}
+	client, err := p.resource(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hostList, err := client.List(ctx, hostOptions)
+	if err != nil {
+		return nil, p.hostError(err, "")
+	}
+
+	pred := storageutil.Predicate(options)
+	var items []runtime.Object
+	for i := range hostList.Items {
+		obj, err := p.convert(&hostList.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		if obj == nil {
+			continue
+		}
+		if ok, err := pred.Matches(obj); err != nil || !ok {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		items = append(items, obj)
+	}
+
+	list := p.NewList()
+	if err := meta.SetList(list, items); err != nil {
+		return nil, err
+	}
+	listAccessor, err := meta.ListAccessor(list)
+	if err != nil {
+		return nil, err
+	}
+	listAccessor.SetResourceVersion(hostList.GetResourceVersion())
+	listAccessor.SetContinue(hostList.GetContinue())
+	return list, nil
+}
+
+// Watch forwards the events of the host resource, with the objects converted.  Objects modified so that they are no
+// longer served are reported as deleted, with only their name, namespace and resource version set, as the object
+// served before the modification is unknown.  Objects modified so that they no longer match the selectors of the
+// watch are not reported as deleted.
+func (p *passthroughREST) Watch(ctx context.Context, options *metainternalversion.ListOptions) (watch.Interface, error) {
+	hostOptions := metav1.ListOptions{Watch: true}
+	if options != nil {
+		hostOptions.ResourceVersion = options.ResourceVersion
+		hostOptions.ResourceVersionMatch = options.ResourceVersionMatch
+		hostOptions.AllowWatchBookmarks = options.AllowWatchBookmarks
+		hostOptions.SendInitialEvents = options.SendInitialEvents
+		hostOptions.TimeoutSeconds = options.TimeoutSeconds
+	}
+	client, err := p.resource(ctx)
+	if err != nil {
+		return nil, err
+	}
+	w, err := client.Watch(ctx, hostOptions)
+	if err != nil {
+		return nil, p.hostError(err, "")
+	}
+
+	pred := storageutil.Predicate(options)
+	return watch.Filter(w, func(in watch.Event) (watch.Event, bool) {
+		if in.Type == watch.Error {
+			return in, true
+		}
+		u, ok := in.Object.(*unstructured.Unstructured)
+		if !ok {
+			return watchError(fmt.Errorf("unexpected object %T in the watch of %v", in.Object, p.host)), true
+		}
+		if in.Type == watch.Bookmark {
+			bookmark, err := p.bookmark(u)
+			if err != nil {
+				return watchError(err), true
+			}
+			return watch.Event{Type: watch.Bookmark, Object: bookmark}, true
+		}
+		obj, err := p.convert(u)
+		if err != nil {
+			return watchError(err), true
+		}
+		if obj == nil {
+			if in.Type != watch.Modified {
+				return in, false
+			}
+			if obj, err = p.deleted(u); err != nil {
+				return watchError(err), true
+			}
+			return watch.Event{Type: watch.Deleted, Object: obj}, true
+		}
+		if ok, err := pred.Matches(obj); err != nil || !ok {
+			return in, false
+		}
+		return watch.Event{Type: in.Type, Object: obj}, true
+	}), nil
+}
+
+func (p *writablePassthroughREST) Create(
+	ctx context.Context,
+	obj runtime.Object,
+	createValidation rest.ValidateObjectFunc,
+	options *metav1.CreateOptions,
+) (runtime.Object, error) {
+	if createValidation != nil {
+		if err := createValidation(ctx, obj); err != nil {
+			return nil, err
+		}
+	}
+	if options == nil {
+		options = &metav1.CreateOptions{}
+	}
+	u, err := p.toHost(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+	client, err := p.resource(ctx)
+	if err != nil {
+		return nil, err
+	}
+	created, err := client.Create(ctx, u, *options)
+	if err != nil {
+		return nil, p.hostError(err, u.GetName())
+	}
+	return p.convertWritten(created)
+}
+
+func (p *writablePassthroughREST) Update(
+	ctx context.Context,
+	name string,
+	objInfo rest.UpdatedObjectInfo,
+	createValidation rest.ValidateObjectFunc,
+	updateValidation rest.ValidateObjectUpdateFunc,
+	forceAllowCreate bool,
+	options *metav1.UpdateOptions,
+) (runtime.Object, bool, error) {
+	if options == nil {
+		options = &metav1.UpdateOptions{}
+	}
+	oldObj, err := p.Get(ctx, name, &metav1.GetOptions{})
+	if err != nil {
+		if !forceAllowCreate || !apierrors.IsNotFound(err) {
+			return nil, false, err
+		}
+		obj, err := objInfo.UpdatedObject(ctx, nil)
+		if err != nil {
+			return nil, false, err
+		}
+		created, err := p.Create(ctx, obj, createValidation, &metav1.CreateOptions{
+			DryRun:       options.DryRun,
+			FieldManager: options.FieldManager,
+		})
+		return created, err == nil, err
+	}
+
+	obj, err := objInfo.UpdatedObject(ctx, oldObj)
+	if err != nil {
+		return nil, false, err
+	}
+	if updateValidation != nil {
+		if err := updateValidation(ctx, obj, oldObj); err != nil {
+			return nil, false, err
+		}
+	}
+	u, err := p.toHost(ctx, obj)
+	if err != nil {
+		return nil, false, err
+	}
+	client, err := p.resource(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	updated, err := client.Update(ctx, u, *options)
+	if err != nil {
+		return nil, false, p.hostError(err, name)
+	}
+	updatedObj, err := p.convertWritten(updated)
+	return updatedObj, false, err
+}
+
+// resource returns the client of the host resource in the namespace of the request.  Unless WithoutImpersonation is
+// set, the client impersonates the user of the request, and requests without a user are rejected.
+func (p *passthroughREST) resource(ctx context.Context) (dynamic.ResourceInterface, error) {
+	if !p.withoutImpersonation {
+		if _, ok := genericapirequest.UserFrom(ctx); !ok {
+			return nil, apierrors.NewUnauthorized(
+				fmt.Sprintf("no user to impersonate in the requests to %v", p.host.GroupResource()))
+		}
+	}
+	if ns, _ := genericapirequest.NamespaceFrom(ctx); p.isNamespaced && ns != "" {
+		return p.client.Namespace(ns), nil
+	}
+	return p.client, nil
+}
+
+// impersonatingRoundTripper impersonates the user in the context of each request, so that the requests of all the
+// users share the connections of a single client.
+type impersonatingRoundTripper struct {
+	delegate http.RoundTripper
+}
+
+func (rt *impersonatingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	user, ok := genericapirequest.UserFrom(req.Context())
+	if !ok {
+		return nil, fmt.Errorf("no user to impersonate in the request to %v", req.URL)
+	}
+	return transport.NewImpersonatingRoundTripper(transport.ImpersonationConfig{
+		UserName: user.GetName(),
+		UID:      user.GetUID(),
+		Groups:   user.GetGroups(),
+		Extra:    user.GetExtra(),
+	}, rt.delegate).RoundTrip(req)
+}
+
+func (rt *impersonatingRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return rt.delegate
+}
+
+// convert converts an object of the host resource to the object served, nil if it isn't served.
+func (p *passthroughREST) convert(u *unstructured.Unstructured) (runtime.Object, error) {
+	host := p.newHostFunc()
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), host); err != nil {
+		return nil, err
+	}
+	return p.toObject(host)
+}
+
+// convertWritten converts an object written to the host resource, which must be served.
+func (p *writablePassthroughREST) convertWritten(u *unstructured.Unstructured) (runtime.Object, error) {
+	obj, err := p.convert(u)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, apierrors.NewInternalError(fmt.Errorf("%v %q written is not served", p.host, u.GetName()))
+	}
+	return obj, nil
+}
+
+// toHost converts the object served to an object of the host resource.
+func (p *writablePassthroughREST) toHost(ctx context.Context, obj runtime.Object) (*unstructured.Unstructured, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	host, err := p.fromObject(obj)
+	if err != nil {
+		return nil, err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(host)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetName(accessor.GetName())
+	if accessor.GetName() == "" {
+		u.SetGenerateName(accessor.GetGenerateName())
+	}
+	if ns, _ := genericapirequest.NamespaceFrom(ctx); p.isNamespaced && ns != "" {
+		u.SetNamespace(ns)
+	}
+	u.SetResourceVersion(accessor.GetResourceVersion())
+	if u.GetKind() == "" {
+		gvks, _, err := clientgoscheme.Scheme.ObjectKinds(host)
+		if err != nil {
+			return nil, fmt.Errorf("unknown kind of %T, the objects converted must have an apiVersion and a kind: %w",
+				host, err)
+		}
+		u.SetGroupVersionKind(gvks[0])
+	}
+	return u, nil
+}
+
+// bookmark returns the bookmark of the resource served for the bookmark of the host resource.
+func (p *passthroughREST) bookmark(u *unstructured.Unstructured) (runtime.Object, error) {
+	bookmark := p.New()
+	accessor, err := meta.Accessor(bookmark)
+	if err != nil {
+		return nil, err
+	}
+	accessor.SetResourceVersion(u.GetResourceVersion())
+	accessor.SetAnnotations(u.GetAnnotations())
+	return bookmark, nil
+}
+
+// deleted returns the object served deleted for the host object u, with only its name, namespace and resource
+// version.
+func (p *passthroughREST) deleted(u *unstructured.Unstructured) (runtime.Object, error) {
+	obj := p.New()
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	accessor.SetName(u.GetName())
+	accessor.SetNamespace(u.GetNamespace())
+	accessor.SetResourceVersion(u.GetResourceVersion())
+	return obj, nil
+}
+
+// hostError returns the error of the host resource for the resource served.
+func (p *passthroughREST) hostError(err error, name string) error {
+	switch {
+	case apierrors.IsNotFound(err):
+		return apierrors.NewNotFound(p.groupResource, name)
+	case apierrors.IsAlreadyExists(err):
+		return apierrors.NewAlreadyExists(p.groupResource, name)
+	case apierrors.IsConflict(err):
+		var statusErr apierrors.APIStatus
+		if errors.As(err, &statusErr) {
+			return apierrors.NewConflict(p.groupResource, name, errors.New(statusErr.Status().Message))
+		}
+	}
+	return err
+}
+
+func watchError(err error) watch.Event {
+	return watch.Event{Type: watch.Error, Object: &apierrors.NewInternalError(err).ErrStatus}
+}
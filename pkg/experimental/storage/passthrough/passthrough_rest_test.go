@@ -0,0 +1,282 @@
+package passthrough
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/authentication/user"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+	restclient "k8s.io/client-go/rest"
+
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/storage/internal/storageutil/storagetest"
+)
+
+// testTimeout is the time to wait for the events of a watch.
+const testTimeout = 5 * time.Second
+
+func testConfigMap(name string, labels map[string]string) corev1.ConfigMap {
+	return corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Labels: labels, ResourceVersion: "1"},
+		Data:       map[string]string{"spec": name + "-spec"},
+	}
+}
+
+// newTestServer serves the configmaps of the default namespace, as the kube-apiserver does: "a" and "b" can be
+// read, and "skipped" isn't served by the passthrough storage.  Watches then modify "b" so that it isn't served.
+// Created configmaps are returned as is.
+func newTestServer(t *testing.T) *httptest.Server {
+	items := []corev1.ConfigMap{
+		testConfigMap("a", map[string]string{"x": "1"}),
+		testConfigMap("b", map[string]string{"x": "2"}),
+		testConfigMap("skipped", map[string]string{"skip": "true"}),
+	}
+	writeJSON := func(w http.ResponseWriter, code int, obj interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(obj)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/namespaces/default/configmaps", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost:
+			cm := &corev1.ConfigMap{}
+			if err := json.NewDecoder(r.Body).Decode(cm); err != nil {
+				writeJSON(w, http.StatusBadRequest, apierrors.NewBadRequest(err.Error()).ErrStatus)
+				return
+			}
+			cm.ResourceVersion = "2"
+			writeJSON(w, http.StatusCreated, cm)
+		case r.URL.Query().Get("watch") == "true":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			for _, cm := range items {
+				_ = json.NewEncoder(w).Encode(metav1.WatchEvent{
+					Type:   string(watch.Added),
+					Object: runtime.RawExtension{Object: cm.DeepCopy()},
+				})
+			}
+			skipped := testConfigMap("b", map[string]string{"x": "2", "skip": "true"})
+			skipped.ResourceVersion = "2"
+			_ = json.NewEncoder(w).Encode(metav1.WatchEvent{
+				Type:   string(watch.Modified),
+				Object: runtime.RawExtension{Object: &skipped},
+			})
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			writeJSON(w, http.StatusOK, &corev1.ConfigMapList{
+				TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMapList"},
+				ListMeta: metav1.ListMeta{ResourceVersion: "1"},
+				Items:    items,
+			})
+		}
+	})
+	mux.HandleFunc("/api/v1/namespaces/default/configmaps/", func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path[len("/api/v1/namespaces/default/configmaps/"):]
+		for i := range items {
+			if items[i].Name == name {
+				writeJSON(w, http.StatusOK, &items[i])
+				return
+			}
+		}
+		writeJSON(w, http.StatusNotFound,
+			apierrors.NewNotFound(corev1.Resource("configmaps"), name).ErrStatus)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func toTestObject(host runtime.Object) (runtime.Object, error) {
+	cm := host.(*corev1.ConfigMap)
+	if cm.Labels["skip"] == "true" {
+		return nil, nil
+	}
+	return &storagetest.Object{ObjectMeta: cm.ObjectMeta, Spec: cm.Data["spec"]}, nil
+}
+
+func fromTestObject(obj runtime.Object) (runtime.Object, error) {
+	return &corev1.ConfigMap{Data: map[string]string{"spec": obj.(*storagetest.Object).Spec}}, nil
+}
+
+func newTestREST(t *testing.T, opts ...Option) rest.Storage {
+	return newTestRESTForHost(t, newTestServer(t).URL, opts...)
+}
+
+func newTestRESTForHost(t *testing.T, host string, opts ...Option) rest.Storage {
+	opts = append(opts, WithClientConfig(&restclient.Config{Host: host}))
+	storage, err := NewPassthroughStorageProvider(
+		&storagetest.Object{},
+		corev1.SchemeGroupVersion.WithResource("configmaps"),
+		func() runtime.Object { return &corev1.ConfigMap{} },
+		toTestObject,
+		opts...,
+	)(runtime.NewScheme(), nil)
+	assert.NoError(t, err)
+	return storage
+}
+
+func testContext() context.Context {
+	ctx := genericapirequest.WithNamespace(context.TODO(), "default")
+	return genericapirequest.WithUser(ctx, &user.DefaultInfo{
+		Name:   "test-user",
+		UID:    "test-uid",
+		Groups: []string{"test-group"},
+		Extra:  map[string][]string{"scopes": {"test-scope"}},
+	})
+}
+
+func TestPassthroughRESTGet(t *testing.T) {
+	p := newTestREST(t).(*passthroughREST)
+
+	obj, err := p.Get(testContext(), "a", &metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "a", obj.(*storagetest.Object).Name)
+	assert.Equal(t, "a-spec", obj.(*storagetest.Object).Spec)
+	assert.Equal(t, "1", obj.(*storagetest.Object).ResourceVersion)
+
+	t.Run("missing objects should be not found in the resource served", func(t *testing.T) {
+		_, err := p.Get(testContext(), "missing", &metav1.GetOptions{})
+		assert.True(t, apierrors.IsNotFound(err))
+		details := err.(apierrors.APIStatus).Status().Details
+		assert.Equal(t, "test.k8s.io", details.Group)
+		assert.Equal(t, "testobjects", details.Kind)
+	})
+	t.Run("objects not served should be not found", func(t *testing.T) {
+		_, err := p.Get(testContext(), "skipped", &metav1.GetOptions{})
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+}
+
+func TestPassthroughRESTList(t *testing.T) {
+	p := newTestREST(t).(*passthroughREST)
+
+	list, err := p.List(testContext(), &metainternalversion.ListOptions{})
+	assert.NoError(t, err)
+	var names []string
+	for _, item := range list.(*storagetest.ObjectList).Items {
+		names = append(names, item.Name)
+	}
+	assert.Equal(t, []string{"a", "b"}, names)
+	assert.Equal(t, "1", list.(*storagetest.ObjectList).ResourceVersion)
+
+	t.Run("selectors should apply to the objects served", func(t *testing.T) {
+		list, err := p.List(testContext(), &metainternalversion.ListOptions{
+			LabelSelector: labels.SelectorFromSet(labels.Set{"x": "2"}),
+		})
+		assert.NoError(t, err)
+		assert.Len(t, list.(*storagetest.ObjectList).Items, 1)
+		assert.Equal(t, "b", list.(*storagetest.ObjectList).Items[0].Name)
+	})
+}
+
+func TestPassthroughRESTWatch(t *testing.T) {
+	p := newTestREST(t).(*passthroughREST)
+
+	w, err := p.Watch(testContext(), &metainternalversion.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{"x": "2"}),
+	})
+	assert.NoError(t, err)
+	defer w.Stop()
+	select {
+	case ev := <-w.ResultChan():
+		assert.Equal(t, watch.Added, ev.Type)
+		assert.Equal(t, "b", ev.Object.(*storagetest.Object).Name)
+		assert.Equal(t, "b-spec", ev.Object.(*storagetest.Object).Spec)
+	case <-time.After(testTimeout):
+		t.Fatal("expected an event")
+	}
+	// modified so that it isn't served
+	select {
+	case ev := <-w.ResultChan():
+		assert.Equal(t, watch.Deleted, ev.Type)
+		assert.Equal(t, "b", ev.Object.(*storagetest.Object).Name)
+		assert.Equal(t, "default", ev.Object.(*storagetest.Object).Namespace)
+		assert.Equal(t, "2", ev.Object.(*storagetest.Object).ResourceVersion)
+	case <-time.After(testTimeout):
+		t.Fatal("expected an event")
+	}
+	select {
+	case ev := <-w.ResultChan():
+		t.Fatalf("unexpected event %v", ev)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestPassthroughRESTWrites(t *testing.T) {
+	_, ok := newTestREST(t).(rest.Creater)
+	assert.False(t, ok, "the storage should be read-only by default")
+
+	p := newTestREST(t, WithWrites(fromTestObject)).(*writablePassthroughREST)
+	created, err := p.Create(testContext(), &storagetest.Object{
+		ObjectMeta: metav1.ObjectMeta{Name: "c"},
+		Spec:       "c-spec",
+	}, nil, &metav1.CreateOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "c", created.(*storagetest.Object).Name)
+	assert.Equal(t, "default", created.(*storagetest.Object).Namespace)
+	assert.Equal(t, "c-spec", created.(*storagetest.Object).Spec)
+	assert.Equal(t, "2", created.(*storagetest.Object).ResourceVersion)
+}
+
+func TestPassthroughRESTImpersonation(t *testing.T) {
+	server := newTestServer(t)
+	var mu sync.Mutex
+	var headers []http.Header
+	recording := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		headers = append(headers, r.Header.Clone())
+		mu.Unlock()
+		server.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(recording.Close)
+	lastHeader := func() http.Header {
+		mu.Lock()
+		defer mu.Unlock()
+		return headers[len(headers)-1]
+	}
+
+	p := newTestRESTForHost(t, recording.URL).(*passthroughREST)
+	_, err := p.Get(testContext(), "a", &metav1.GetOptions{})
+	assert.NoError(t, err)
+	header := lastHeader()
+	assert.Equal(t, "test-user", header.Get("Impersonate-User"))
+	assert.Equal(t, "test-uid", header.Get("Impersonate-Uid"))
+	assert.Equal(t, []string{"test-group"}, header.Values("Impersonate-Group"))
+	assert.Equal(t, "test-scope", header.Get("Impersonate-Extra-Scopes"))
+
+	t.Run("requests should impersonate the user of each request", func(t *testing.T) {
+		ctx := genericapirequest.WithUser(genericapirequest.WithNamespace(context.TODO(), "default"),
+			&user.DefaultInfo{Name: "other-user"})
+		_, err := p.Get(ctx, "a", &metav1.GetOptions{})
+		assert.NoError(t, err)
+		header := lastHeader()
+		assert.Equal(t, "other-user", header.Get("Impersonate-User"))
+		assert.Empty(t, header.Values("Impersonate-Group"))
+	})
+	t.Run("requests without a user should be rejected", func(t *testing.T) {
+		_, err := p.Get(genericapirequest.WithNamespace(context.TODO(), "default"), "a", &metav1.GetOptions{})
+		assert.True(t, apierrors.IsUnauthorized(err))
+	})
+	t.Run("requests should not impersonate their user without impersonation", func(t *testing.T) {
+		p := newTestRESTForHost(t, recording.URL, WithoutImpersonation()).(*passthroughREST)
+		_, err := p.Get(testContext(), "a", &metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Empty(t, lastHeader().Get("Impersonate-User"))
+	})
+}
@@ -0,0 +1,71 @@
+// Package polling adds watches to storages which can only get and list their objects, e.g. storages computing
+// their objects on the fly, by listing the objects periodically.
+package polling
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/registry/generic"
+	"k8s.io/apiserver/pkg/registry/rest"
+
+	builderrest "sigs.k8s.io/apiserver-runtime/pkg/builder/rest"
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/storage/internal/broadcaster"
+)
+
+// DefaultInterval is the interval between the lists of the objects watched, unless set with WithInterval.
+const DefaultInterval = 10 * time.Second
+
+// Storage is the storage of a resource watched by polling: it must list the objects of the resource, whose names
+// identify them across lists.
+type Storage interface {
+	rest.Lister
+	rest.Scoper
+	New() runtime.Object
+}
+
+// Option configures the storage returned by NewPollingWatchProvider.
+type Option func(*pollingREST)
+
+// WithInterval sets the interval between the lists of the objects watched.  A short interval reports changes
+// sooner, but lists the objects more often.
+func WithInterval(interval time.Duration) Option {
+	return func(p *pollingREST) {
+		p.interval = interval
+	}
+}
+
+// NewPollingWatchProvider serves the gets and lists of s, and watches: from the first get, list or watch until the
+// apiserver stops, the objects of s in every namespace are listed every interval, and the objects added, deleted,
+// and modified -- whose content changed -- since the previous list are reported to all the watches.  The changes
+// have resource versions generated by the polling rather than by s, and the objects got, the lists and their
+// objects have the resource version of the last change.  The most recent changes are kept so that watches resume
+// from the resource version of a list or of a change.  The label and field selectors of the watches apply to the
+// metadata of the objects.
+//
+// Only gets, lists and watches are served, the storage of resources with other verbs should implement rest.Watcher
+// instead:
+//
+//	builder.APIServer.
+//	  WithResourceAndHandler(&v1alpha1.Fortune{}, polling.NewPollingWatchProvider(
+//	        &v1alpha1.Fortune{}, polling.WithInterval(time.Minute))).
+//	  Build()
+func NewPollingWatchProvider(s Storage, opts ...Option) builderrest.ResourceHandlerProvider {
+	return func(scheme *runtime.Scheme, getter generic.RESTOptionsGetter) (rest.Storage, error) {
+		p := &pollingREST{
+			storage:  s,
+			interval: DefaultInterval,
+		}
+		for _, opt := range opts {
+			opt(p)
+		}
+		p.ctx, p.cancel = context.WithCancel(context.Background())
+		// resource version 0 means any resource version to clients
+		p.broadcaster = broadcaster.New(1, s.New)
+		if g, ok := s.(rest.Getter); ok {
+			return &getterPollingREST{pollingREST: p, getter: g}, nil
+		}
+		return p, nil
+	}
+}
@@ -0,0 +1,289 @@
+package polling
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/storage/internal/broadcaster"
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/storage/internal/storageutil"
+)
+
+var _ rest.Lister = &pollingREST{}
+var _ rest.Watcher = &pollingREST{}
+var _ rest.Scoper = &pollingREST{}
+var _ rest.Getter = &getterPollingREST{}
+
+type pollingREST struct {
+	storage  Storage
+	interval time.Duration
+
+	// broadcaster sends the changes polled to the watches, and keeps the recent ones to resume watches from
+	broadcaster *broadcaster.Broadcaster
+	// ctx is the context of the polls, cancelled when the storage is destroyed
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// mu guards the objects of the last poll, and the start of the polls
+	mu      sync.Mutex
+	objects map[objectKey]polledObject
+	started bool
+}
+
+// getterPollingREST serves gets in addition, as the verbs served depend on the interfaces implemented by the
+// storage.
+type getterPollingREST struct {
+	*pollingREST
+	getter rest.Getter
+}
+
+func (p *pollingREST) New() runtime.Object {
+	return p.storage.New()
+}
+
+func (p *pollingREST) Destroy() {
+	p.cancel()
+	p.broadcaster.Shutdown()
+	if d, ok := p.storage.(interface{ Destroy() }); ok {
+		d.Destroy()
+	}
+}
+
+func (p *pollingREST) NewList() runtime.Object {
+	return p.storage.NewList()
+}
+
+func (p *pollingREST) NamespaceScoped() bool {
+	return p.storage.NamespaceScoped()
+}
+
+func (p *pollingREST) ConvertToTable(ctx context.Context, object runtime.Object, tableOptions runtime.Object) (*metav1.Table, error) {
+	return p.storage.ConvertToTable(ctx, object, tableOptions)
+}
+
+// Get gets an object of the storage, with the resource version of the last change polled as for lists.
+func (p *getterPollingREST) Get(ctx context.Context, name string, options *metav1.GetOptions) (runtime.Object, error) {
+	if err := p.start(); err != nil {
+		return nil, err
+	}
+	rv := strconv.FormatUint(p.broadcaster.ResourceVersion(), 10)
+	obj, err := p.getter.Get(ctx, name, options)
+	if err != nil {
+		return nil, err
+	}
+	// the storage may return the object it keeps
+	obj = obj.DeepCopyObject()
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	accessor.SetResourceVersion(rv)
+	return obj, nil
+}
+
+// List lists the objects of the storage.  The list and its objects have the resource version of the last change
+// polled, so that clients can watch the changes from the list: the changes made since then are polled later, with
+// greater resource versions.
+func (p *pollingREST) List(ctx context.Context, options *metainternalversion.ListOptions) (runtime.Object, error) {
+	if err := p.start(); err != nil {
+		return nil, err
+	}
+	// read before listing, so that the changes listed but not polled yet are reported to the watches of the list
+	rv := strconv.FormatUint(p.broadcaster.ResourceVersion(), 10)
+	list, err := p.storage.List(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	// the storage may return the objects it keeps
+	list = list.DeepCopyObject()
+	listAccessor, err := meta.ListAccessor(list)
+	if err != nil {
+		return nil, err
+	}
+	listAccessor.SetResourceVersion(rv)
+	err = meta.EachListItem(list, func(obj runtime.Object) error {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		accessor.SetResourceVersion(rv)
+		return nil
+	})
+	return list, err
+}
+
+// Watch reports the changes polled after the resource version of the watch, or the objects listed followed by the
+// changes polled after the list if the resource version is "" or "0", or if initial events are requested.  Watches
+// from a resource version whose changes are not kept anymore fail with a 410 Gone error.
+func (p *pollingREST) Watch(ctx context.Context, options *metainternalversion.ListOptions) (watch.Interface, error) {
+	if err := p.start(); err != nil {
+		return nil, err
+	}
+	watchOptions, err := storageutil.WatchOptions(
+		ctx, options, p.NamespaceScoped(), p.broadcaster.ResourceVersion(), p.List)
+	if err != nil {
+		return nil, err
+	}
+	return p.broadcaster.Watch(watchOptions)
+}
+
+// polledObject is an object listed, with the hash of its content.
+type polledObject struct {
+	object runtime.Object
+	hash   [sha256.Size]byte
+}
+
+// objectKey identifies an object across lists.
+type objectKey struct {
+	namespace string
+	name      string
+}
+
+// start polls the objects on the first call, then polls them every interval in the background until the storage is
+// destroyed.
+func (p *pollingREST) start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.started {
+		return nil
+	}
+	if err := p.pollLocked(); err != nil {
+		return err
+	}
+	p.started = true
+	go p.run()
+	return nil
+}
+
+// run polls the objects every interval.  Failed polls are retried at the next interval, which reports the changes
+// made meanwhile.
+func (p *pollingREST) run() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.ctx.Done():
+			return
+		}
+		p.mu.Lock()
+		err := p.pollLocked()
+		p.mu.Unlock()
+		if err != nil && p.ctx.Err() == nil {
+			klog.Errorf("Failed polling the objects of %T: %v", p.New(), err)
+		}
+	}
+}
+
+// pollLocked lists the objects of the storage in every namespace, and records the changes since the previous poll
+// with the next resource versions.
+func (p *pollingREST) pollLocked() error {
+	current, err := p.poll(p.ctx)
+	if err != nil {
+		return err
+	}
+	rv := p.broadcaster.ResourceVersion()
+	for _, ev := range diff(p.objects, current) {
+		rv++
+		ev.ResourceVersion = rv
+		for _, obj := range []runtime.Object{ev.Object, ev.OldObject} {
+			if accessor, err := meta.Accessor(obj); obj != nil && err == nil {
+				accessor.SetResourceVersion(strconv.FormatUint(rv, 10))
+			}
+		}
+		p.broadcaster.Action(ev)
+	}
+	p.objects = current
+	return nil
+}
+
+// poll lists the objects of the storage, by key.
+func (p *pollingREST) poll(ctx context.Context) (map[objectKey]polledObject, error) {
+	list, err := p.storage.List(ctx, &metainternalversion.ListOptions{
+		LabelSelector: labels.Everything(),
+		FieldSelector: fields.Everything(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+	objects := make(map[objectKey]polledObject, len(items))
+	for _, item := range items {
+		obj := item.DeepCopyObject()
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return nil, err
+		}
+		// the resource versions are the storage's, changes are detected from the content only
+		accessor.SetResourceVersion("")
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		key := objectKey{namespace: accessor.GetNamespace(), name: accessor.GetName()}
+		objects[key] = polledObject{object: obj, hash: sha256.Sum256(data)}
+	}
+	return objects, nil
+}
+
+// diff returns the events of the changes from objects to current, in the order of the keys of the objects.  The
+// objects of the events are copies.
+func diff(objects, current map[objectKey]polledObject) []broadcaster.Event {
+	var events []broadcaster.Event
+	for _, key := range sortedKeys(current) {
+		old, found := objects[key]
+		switch {
+		case !found:
+			events = append(events, broadcaster.Event{
+				Type:   watch.Added,
+				Object: current[key].object.DeepCopyObject(),
+			})
+		case old.hash != current[key].hash:
+			events = append(events, broadcaster.Event{
+				Type:      watch.Modified,
+				Object:    current[key].object.DeepCopyObject(),
+				OldObject: old.object.DeepCopyObject(),
+			})
+		}
+	}
+	for _, key := range sortedKeys(objects) {
+		if _, found := current[key]; !found {
+			events = append(events, broadcaster.Event{
+				Type:   watch.Deleted,
+				Object: objects[key].object.DeepCopyObject(),
+			})
+		}
+	}
+	return events
+}
+
+func sortedKeys(objects map[objectKey]polledObject) []objectKey {
+	keys := make([]objectKey, 0, len(objects))
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].namespace != keys[j].namespace {
+			return keys[i].namespace < keys[j].namespace
+		}
+		return keys[i].name < keys[j].name
+	})
+	return keys
+}
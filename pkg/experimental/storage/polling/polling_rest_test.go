@@ -0,0 +1,236 @@
+package polling
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/utils/ptr"
+
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/storage/internal/storageutil/storagetest"
+)
+
+// testTimeout is the time to wait for the events of a watch.
+const testTimeout = 5 * time.Second
+
+// testStorage lists the spec of its objects by name, and fails listing once err is set.
+type testStorage struct {
+	rest.TableConvertor
+
+	mu    sync.Mutex
+	specs map[string]string
+	err   error
+}
+
+func (s *testStorage) set(specs map[string]string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.specs = specs
+	s.err = err
+}
+
+func (s *testStorage) New() runtime.Object {
+	return &storagetest.Object{}
+}
+
+func (s *testStorage) NewList() runtime.Object {
+	return &storagetest.ObjectList{}
+}
+
+func (s *testStorage) NamespaceScoped() bool {
+	return false
+}
+
+func (s *testStorage) List(context.Context, *metainternalversion.ListOptions) (runtime.Object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	list := &storagetest.ObjectList{}
+	for _, name := range sets.List(sets.KeySet(s.specs)) {
+		spec := s.specs[name]
+		list.Items = append(list.Items, storagetest.Object{
+			// the resource version changes on every list, as with storages computing their objects
+			ObjectMeta: metav1.ObjectMeta{Name: name, ResourceVersion: time.Now().String()},
+			Spec:       spec,
+		})
+	}
+	return list, nil
+}
+
+func newTestREST(t *testing.T, s Storage) rest.Storage {
+	storage, err := NewPollingWatchProvider(s, WithInterval(10*time.Millisecond))(runtime.NewScheme(), nil)
+	assert.NoError(t, err)
+	t.Cleanup(storage.Destroy)
+	return storage
+}
+
+// nextEvents returns the next n events of w.
+func nextEvents(t *testing.T, w watch.Interface, n int) []watch.Event {
+	var events []watch.Event
+	for len(events) < n {
+		select {
+		case ev, ok := <-w.ResultChan():
+			if !ok {
+				t.Fatalf("watch closed after %v", events)
+			}
+			events = append(events, ev)
+		case <-time.After(testTimeout):
+			t.Fatalf("expected %d events, got %v", n, events)
+		}
+	}
+	return events
+}
+
+type testEvent struct {
+	eventType       watch.EventType
+	name            string
+	resourceVersion string
+}
+
+func testEvents(events []watch.Event) []testEvent {
+	var out []testEvent
+	for _, ev := range events {
+		obj := ev.Object.(*storagetest.Object)
+		out = append(out, testEvent{ev.Type, obj.Name, obj.ResourceVersion})
+	}
+	return out
+}
+
+func TestPollingRESTWatch(t *testing.T) {
+	s := &testStorage{specs: map[string]string{"a": "1", "b": "1"}}
+	p := newTestREST(t, s).(*pollingREST)
+
+	list, err := p.List(context.TODO(), &metainternalversion.ListOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "3", list.(*storagetest.ObjectList).ResourceVersion)
+	assert.Equal(t, "3", list.(*storagetest.ObjectList).Items[0].ResourceVersion,
+		"the objects should have the resource version of the list")
+
+	w, err := p.Watch(context.TODO(), &metainternalversion.ListOptions{})
+	assert.NoError(t, err)
+	defer w.Stop()
+	assert.Equal(t, []testEvent{
+		{watch.Added, "a", "3"},
+		{watch.Added, "b", "3"},
+	}, testEvents(nextEvents(t, w, 2)), "the objects listed should be added first")
+
+	s.set(map[string]string{"b": "2", "c": "1"}, nil)
+	assert.Equal(t, []testEvent{
+		{watch.Modified, "b", "4"},
+		{watch.Added, "c", "5"},
+		{watch.Deleted, "a", "6"},
+	}, testEvents(nextEvents(t, w, 3)))
+
+	t.Run("lists should have the resource version of the last event", func(t *testing.T) {
+		list, err := p.List(context.TODO(), &metainternalversion.ListOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "6", list.(*storagetest.ObjectList).ResourceVersion)
+	})
+	t.Run("watches should report the changes after a failed poll", func(t *testing.T) {
+		s.set(nil, apierrors.NewServiceUnavailable("unavailable"))
+		time.Sleep(50 * time.Millisecond)
+		s.set(map[string]string{"b": "2", "c": "1", "d": "1"}, nil)
+		assert.Equal(t, []testEvent{{watch.Added, "d", "7"}}, testEvents(nextEvents(t, w, 1)))
+	})
+	t.Run("selectors should apply to the changes", func(t *testing.T) {
+		w, err := p.Watch(context.TODO(), &metainternalversion.ListOptions{
+			ResourceVersion: "6",
+			FieldSelector:   fields.OneTermEqualSelector("metadata.name", "d"),
+		})
+		assert.NoError(t, err)
+		defer w.Stop()
+		assert.Equal(t, []testEvent{{watch.Added, "d", "7"}}, testEvents(nextEvents(t, w, 1)))
+	})
+}
+
+func TestPollingRESTWatchFromResourceVersion(t *testing.T) {
+	s := &testStorage{specs: map[string]string{"a": "1"}}
+	p := newTestREST(t, s).(*pollingREST)
+
+	list, err := p.List(context.TODO(), &metainternalversion.ListOptions{})
+	assert.NoError(t, err)
+	rv := list.(*storagetest.ObjectList).ResourceVersion
+	assert.Equal(t, "2", rv)
+
+	// changed before the watch starts, and possibly polled before
+	s.set(map[string]string{"a": "1", "b": "1"}, nil)
+	time.Sleep(50 * time.Millisecond)
+	w, err := p.Watch(context.TODO(), &metainternalversion.ListOptions{ResourceVersion: rv})
+	assert.NoError(t, err)
+	defer w.Stop()
+	assert.Equal(t, []testEvent{{watch.Added, "b", "3"}}, testEvents(nextEvents(t, w, 1)),
+		"the changes since the list should be reported, without the objects listed")
+
+	t.Run("initial events should end with a bookmark", func(t *testing.T) {
+		w, err := p.Watch(context.TODO(), &metainternalversion.ListOptions{
+			ResourceVersion:     rv,
+			SendInitialEvents:   ptr.To(true),
+			AllowWatchBookmarks: true,
+		})
+		assert.NoError(t, err)
+		defer w.Stop()
+		events := nextEvents(t, w, 3)
+		assert.Equal(t, watch.Added, events[0].Type)
+		assert.Equal(t, watch.Added, events[1].Type)
+		assert.Equal(t, watch.Bookmark, events[2].Type)
+		assert.Equal(t, "true", events[2].Object.(*storagetest.Object).Annotations[metav1.InitialEventsAnnotationKey])
+		assert.Equal(t, events[1].Object.(*storagetest.Object).ResourceVersion, events[2].Object.(*storagetest.Object).ResourceVersion)
+	})
+	t.Run("failed lists should fail the watch", func(t *testing.T) {
+		s := &testStorage{err: errors.New("failed")}
+		_, err := newTestREST(t, s).(*pollingREST).Watch(context.TODO(), &metainternalversion.ListOptions{})
+		assert.Error(t, err)
+	})
+}
+
+func TestPollingRESTWatchExpired(t *testing.T) {
+	s := &testStorage{specs: map[string]string{"a": "1", "b": "1", "c": "1"}}
+	p := newTestREST(t, s).(*pollingREST)
+	p.broadcaster.HistorySize = 1
+
+	list, err := p.List(context.TODO(), &metainternalversion.ListOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "4", list.(*storagetest.ObjectList).ResourceVersion)
+
+	_, err = p.Watch(context.TODO(), &metainternalversion.ListOptions{ResourceVersion: "1"})
+	assert.True(t, apierrors.IsResourceExpired(err), "expected 410 Gone, got %v", err)
+
+	w, err := p.Watch(context.TODO(), &metainternalversion.ListOptions{ResourceVersion: "3"})
+	assert.NoError(t, err, "the last change should be kept")
+	defer w.Stop()
+	assert.Equal(t, []testEvent{{watch.Added, "c", "4"}}, testEvents(nextEvents(t, w, 1)))
+}
+
+func TestPollingRESTGetter(t *testing.T) {
+	_, ok := newTestREST(t, &testStorage{}).(rest.Getter)
+	assert.False(t, ok, "gets should be served only if the storage serves them")
+
+	storage := newTestREST(t, &testGetterStorage{testStorage{specs: map[string]string{"a": "1"}}})
+	obj, err := storage.(rest.Getter).Get(context.TODO(), "a", &metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "a", obj.(*storagetest.Object).Name)
+	assert.Equal(t, "2", obj.(*storagetest.Object).ResourceVersion, "expected the resource version of the last change")
+	_, ok = storage.(rest.Watcher)
+	assert.True(t, ok)
+}
+
+type testGetterStorage struct {
+	testStorage
+}
+
+func (s *testGetterStorage) Get(_ context.Context, name string, _ *metav1.GetOptions) (runtime.Object, error) {
+	return &storagetest.Object{ObjectMeta: metav1.ObjectMeta{Name: name, ResourceVersion: "storage"}}, nil
+}
@@ -0,0 +1,56 @@
+// Package sqlite provides sqlite storage related utilities.
+package sqlite
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/k3s-io/kine/pkg/endpoint"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/registry/generic"
+	genericregistry "k8s.io/apiserver/pkg/registry/generic/registry"
+
+	builderrest "sigs.k8s.io/apiserver-runtime/pkg/builder/rest"
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/storage/internal/kine"
+)
+
+// NewSQLiteStorageProvider replaces underlying persistent layer (which by default is etcd) w/ a SQLite database
+// file at path, created if needed.  The resources keep the semantics of etcd -- resource versions, watches and the
+// watch cache -- as kine serves the etcd API in front of the database, on a unix socket next to it.
+//
+// All the resources stored at path share the database and kine, which stops when the server shuts down.  SQLite
+// requires cgo.
+//
+// An example of storing example resource to SQLite will be:
+//
+//	builder.APIServer.
+//	  WithResourceAndHandler(&v1alpha1.ExampleResource{}, builderrest.NewWithFn(&v1alpha1.ExampleResource{},
+//	        sqlite.NewSQLiteStorageProvider("data/state.db"))).
+//	  Build()
+func NewSQLiteStorageProvider(path string) builderrest.StoreFn {
+	config := endpoint.Config{
+		Endpoint: fmt.Sprintf("sqlite://%s?_journal=WAL&cache=shared&_busy_timeout=30000", path),
+		Listener: "unix://" + path + ".sock",
+	}
+	return func(scheme *runtime.Scheme, s *genericregistry.Store, options *generic.StoreOptions) {
+		options.RESTOptions = &sqliteRESTOptionsGetter{
+			path:              path,
+			RESTOptionsGetter: kine.NewRESTOptionsGetter(scheme, config, s.StorageVersioner, options.RESTOptions),
+		}
+	}
+}
+
+// sqliteRESTOptionsGetter creates the directory of the database before starting kine.
+type sqliteRESTOptionsGetter struct {
+	generic.RESTOptionsGetter
+	path string
+}
+
+func (g *sqliteRESTOptionsGetter) GetRESTOptions(resource schema.GroupResource, example runtime.Object) (generic.RESTOptions, error) {
+	if err := os.MkdirAll(filepath.Dir(g.path), 0700); err != nil {
+		return generic.RESTOptions{}, err
+	}
+	return g.RESTOptionsGetter.GetRESTOptions(resource, example)
+}
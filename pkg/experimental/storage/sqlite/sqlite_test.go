@@ -0,0 +1,76 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+
+	builderrest "sigs.k8s.io/apiserver-runtime/pkg/builder/rest"
+
+	"sigs.k8s.io/apiserver-runtime/pkg/experimental/storage/internal/storageutil/storagetest"
+)
+
+func TestSQLiteStorageProvider(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(storagetest.GroupVersion, &storagetest.Object{}, &storagetest.ObjectList{})
+	metav1.AddToGroupVersion(scheme, storagetest.GroupVersion)
+	path := filepath.Join(t.TempDir(), "data", "state.db")
+
+	storage, err := builderrest.NewWithFn(&storagetest.Object{}, NewSQLiteStorageProvider(path))(scheme, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer storage.Destroy()
+	s := storage.(rest.StandardStorage)
+	ctx := genericapirequest.WithNamespace(context.TODO(), "default")
+
+	// the watch cache is initialized asynchronously
+	var w watch.Interface
+	err = wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, wait.ForeverTestTimeout, true,
+		func(context.Context) (bool, error) {
+			w, err = s.Watch(ctx, &metainternalversion.ListOptions{ResourceVersion: "0"})
+			return err == nil, nil
+		})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer w.Stop()
+
+	created, err := s.Create(ctx, &storagetest.Object{ObjectMeta: metav1.ObjectMeta{Name: "foo"}, Spec: "a"},
+		nil, &metav1.CreateOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotEmpty(t, created.(*storagetest.Object).ResourceVersion)
+	assert.FileExists(t, path)
+
+	obj, err := s.Get(ctx, "foo", &metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "a", obj.(*storagetest.Object).Spec)
+
+	select {
+	case ev := <-w.ResultChan():
+		assert.Equal(t, watch.Added, ev.Type)
+		accessor, err := meta.Accessor(ev.Object)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "foo", accessor.GetName())
+		}
+	case <-time.After(wait.ForeverTestTimeout):
+		t.Error("timed out waiting for the watch event")
+	}
+
+	list, err := s.List(ctx, &metainternalversion.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, list.(*storagetest.ObjectList).Items, 1)
+}